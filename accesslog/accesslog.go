@@ -0,0 +1,303 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/internal/response"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// FormatJSON represents the access log format of JSON lines, which is the default format
+	FormatJSON = "json"
+
+	// FormatLogfmt represents the access log format of logfmt lines
+	FormatLogfmt = "logfmt"
+)
+
+// Logger represents the access logger of the REST, gRPC and gRPC-Web requests.
+// The requests of the excluded paths are not logged, and the others are logged at the sampling ratio,
+// except for the failed requests, HTTP status 5xx or gRPC status other than OK, which are always logged.
+type Logger interface {
+	// InstrumentHandler returns the http.Handler which logs the requests of h, labelled by the route name.
+	InstrumentHandler(route string, h http.Handler) http.Handler
+
+	// UnaryServerInterceptor returns the grpc.UnaryServerInterceptor which logs the unary RPCs.
+	UnaryServerInterceptor() grpc.UnaryServerInterceptor
+
+	// StreamServerInterceptor returns the grpc.StreamServerInterceptor which logs the streaming RPCs.
+	StreamServerInterceptor() grpc.StreamServerInterceptor
+}
+
+// Entry represents an access log line.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Protocol   string    `json:"protocol"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Route      string    `json:"route,omitempty"`
+	RPC        string    `json:"rpc,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	Code       string    `json:"code,omitempty"`
+	BytesIn    int64     `json:"bytes_in"`
+	BytesOut   int64     `json:"bytes_out"`
+	LatencyMS  float64   `json:"latency_ms"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	TLSSubject string    `json:"tls_subject,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+}
+
+type accessLogger struct {
+	log     logger.Logger
+	logfmt  bool
+	ratio   float64
+	exclude map[string]struct{}
+}
+
+// New returns the Logger which writes the access log defined in cfg to the output of l.
+func New(cfg config.AccessLog, l logger.Logger) Logger {
+	al := &accessLogger{
+		log:     l,
+		logfmt:  cfg.Format == FormatLogfmt,
+		ratio:   cfg.SamplingRatio,
+		exclude: make(map[string]struct{}, len(cfg.ExcludePaths)),
+	}
+	if al.ratio == 0 {
+		al.ratio = 1
+	}
+	for _, p := range cfg.ExcludePaths {
+		al.exclude[p] = struct{}{}
+	}
+	return al
+}
+
+func (l *accessLogger) InstrumentHandler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.excluded(r.URL.Path) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+		sw := response.NewWriter(w)
+		h.ServeHTTP(sw, r)
+
+		if sw.Status() < http.StatusInternalServerError && !l.sampled() {
+			return
+		}
+		e := Entry{
+			Time:       start,
+			Protocol:   string(metrics.ProtocolHTTP),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Route:      route,
+			Status:     sw.Status(),
+			BytesIn:    body.n,
+			BytesOut:   sw.Size(),
+			LatencyMS:  latency(start),
+			RemoteAddr: r.RemoteAddr,
+			RequestID:  requestid.FromContext(r.Context()),
+		}
+		if r.TLS != nil {
+			e.TLSSubject = subject(r.TLS.PeerCertificates)
+		}
+		l.write(e)
+	})
+}
+
+func (l *accessLogger) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l.excluded(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		res, err := handler(ctx, req)
+		var out int64
+		if err == nil {
+			out = size(res)
+		}
+		l.logRPC(ctx, info.FullMethod, start, size(req), out, err)
+		return res, err
+	}
+}
+
+func (l *accessLogger) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if l.excluded(info.FullMethod) {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		cs := &countingStream{ServerStream: ss}
+		err := handler(srv, cs)
+		l.logRPC(ss.Context(), info.FullMethod, start, cs.in, cs.out, err)
+		return err
+	}
+}
+
+// write writes the entry as a line, the write error is logged but not returned.
+func (l *accessLogger) write(e Entry) {
+	buf := new(bytes.Buffer)
+	if l.logfmt {
+		writeLogfmt(buf, e)
+	} else {
+		// the error is not expected, since Entry has only the marshalable fields
+		json.NewEncoder(buf).Encode(e)
+	}
+
+	_, err := l.log.Writer().Write(buf.Bytes())
+	if err != nil {
+		l.log.Errorf("failed to write the access log: %v", err)
+	}
+}
+
+// logRPC logs the RPC of the full method name, which the protocol, remote address and TLS client certificate are read from ctx.
+func (l *accessLogger) logRPC(ctx context.Context, fullMethod string, start time.Time, in, out int64, err error) {
+	code := status.Code(err)
+	if err == nil && !l.sampled() {
+		return
+	}
+	e := Entry{
+		Time:      start,
+		Protocol:  string(metrics.ProtocolFromContext(ctx)),
+		RPC:       fullMethod,
+		Code:      code.String(),
+		BytesIn:   in,
+		BytesOut:  out,
+		LatencyMS: latency(start),
+		RequestID: requestid.FromContext(ctx),
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			e.RemoteAddr = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			e.TLSSubject = subject(info.State.PeerCertificates)
+		}
+	}
+	l.write(e)
+}
+
+func (l *accessLogger) excluded(path string) bool {
+	_, ok := l.exclude[path]
+	return ok
+}
+
+func (l *accessLogger) sampled() bool {
+	return l.ratio >= 1 || rand.Float64() < l.ratio
+}
+
+// latency returns the elapsed time since start in milliseconds, rounded to microseconds.
+func latency(start time.Time) float64 {
+	return float64(time.Since(start).Round(time.Microsecond)) / float64(time.Millisecond)
+}
+
+// subject returns the subject of the TLS client certificate, or empty string when the client sent no certificate.
+func subject(certs []*x509.Certificate) string {
+	if len(certs) == 0 {
+		return ""
+	}
+	return certs[0].Subject.String()
+}
+
+// size returns the encoded size of the protobuf message, or 0 when msg is not a protobuf message.
+func size(msg interface{}) int64 {
+	if m, ok := msg.(proto.Message); ok {
+		return int64(proto.Size(m))
+	}
+	return 0
+}
+
+// writeLogfmt writes the non-empty fields of the entry as a logfmt line.
+func writeLogfmt(buf *bytes.Buffer, e Entry) {
+	buf.WriteString("time=")
+	buf.WriteString(e.Time.Format(time.RFC3339Nano))
+	field := func(key, val string) {
+		if val == "" {
+			return
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		if strings.IndexFunc(val, func(r rune) bool {
+			return r <= ' ' || r == '=' || r == '"' || r == '\\' || !unicode.IsPrint(r)
+		}) >= 0 {
+			val = strconv.Quote(val)
+		}
+		buf.WriteString(val)
+	}
+	field("protocol", e.Protocol)
+	field("method", e.Method)
+	field("path", e.Path)
+	field("route", e.Route)
+	field("rpc", e.RPC)
+	if e.Status != 0 {
+		field("status", strconv.Itoa(e.Status))
+	}
+	field("code", e.Code)
+	field("bytes_in", strconv.FormatInt(e.BytesIn, 10))
+	field("bytes_out", strconv.FormatInt(e.BytesOut, 10))
+	field("latency_ms", strconv.FormatFloat(e.LatencyMS, 'f', -1, 64))
+	field("remote_addr", e.RemoteAddr)
+	field("tls_subject", e.TLSSubject)
+	field("request_id", e.RequestID)
+	buf.WriteByte('\n')
+}
+
+// countingReader counts the bytes read from the request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.n += int64(n)
+	return n, err
+}
+
+// countingStream counts the bytes of the messages of the grpc.ServerStream.
+type countingStream struct {
+	grpc.ServerStream
+	in  int64
+	out int64
+}
+
+func (s *countingStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.out += size(msg)
+	}
+	return err
+}
+
+func (s *countingStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.in += size(msg)
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func Test_logger_InstrumentHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.AccessLog
+		path   string
+		code   int
+		want   *Entry
+		logfmt string
+	}{
+		{
+			name: "Request is logged as JSON",
+			path: "/sample",
+			code: http.StatusCreated,
+			want: &Entry{
+				Protocol:   "http",
+				Method:     http.MethodPost,
+				Path:       "/sample",
+				Route:      "Sample Handler",
+				Status:     http.StatusCreated,
+				BytesIn:    5,
+				BytesOut:   7,
+				RemoteAddr: "192.0.2.1:1234",
+				RequestID:  "4bf92f3577b34da6",
+			},
+		},
+		{
+			name: "Request is logged as logfmt",
+			cfg: config.AccessLog{
+				Format: FormatLogfmt,
+			},
+			path:   "/sample",
+			code:   http.StatusOK,
+			logfmt: ` protocol=http method=POST path=/sample route="Sample Handler" status=200 bytes_in=5 bytes_out=7 latency_ms=`,
+		},
+		{
+			name: "Excluded path is not logged",
+			cfg: config.AccessLog{
+				ExcludePaths: []string{"/healthz"},
+			},
+			path: "/healthz",
+			code: http.StatusOK,
+		},
+		{
+			name: "Successful request is not logged when it is not sampled",
+			cfg: config.AccessLog{
+				SamplingRatio: 1e-9,
+			},
+			path: "/sample",
+			code: http.StatusOK,
+		},
+		{
+			name: "Failed request is always logged",
+			cfg: config.AccessLog{
+				SamplingRatio: 1e-9,
+			},
+			path: "/sample",
+			code: http.StatusInternalServerError,
+			want: &Entry{
+				Protocol:   "http",
+				Method:     http.MethodPost,
+				Path:       "/sample",
+				Route:      "Sample Handler",
+				Status:     http.StatusInternalServerError,
+				BytesIn:    5,
+				BytesOut:   7,
+				RemoteAddr: "192.0.2.1:1234",
+				RequestID:  "4bf92f3577b34da6",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := New(tt.cfg, logger.NewWriter(buf, logger.InfoLevel, false, false)).InstrumentHandler("Sample Handler", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ioutil.ReadAll(r.Body)
+				w.WriteHeader(tt.code)
+				io.WriteString(w, "created")
+			}))
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader("hello"))
+			req = req.WithContext(requestid.WithID(req.Context(), "4bf92f3577b34da6"))
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			switch {
+			case tt.logfmt != "":
+				if !strings.HasPrefix(buf.String(), "time=") || !strings.Contains(buf.String(), tt.logfmt) ||
+					!strings.HasSuffix(buf.String(), " remote_addr=192.0.2.1:1234 request_id=4bf92f3577b34da6\n") {
+					t.Errorf("InstrumentHandler() log = %q, want %q", buf.String(), tt.logfmt)
+				}
+			case tt.want == nil:
+				if buf.Len() != 0 {
+					t.Errorf("InstrumentHandler() log = %q, want empty", buf.String())
+				}
+			default:
+				var got Entry
+				if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+					t.Fatalf("InstrumentHandler() log = %q, error = %v", buf.String(), err)
+				}
+				if got.Time.IsZero() || got.LatencyMS < 0 {
+					t.Errorf("InstrumentHandler() time = %v, latency = %v", got.Time, got.LatencyMS)
+				}
+				got.Time, got.LatencyMS = time.Time{}, 0
+				if got != *tt.want {
+					t.Errorf("InstrumentHandler() = %+v, want %+v", got, *tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_logger_UnaryServerInterceptor(t *testing.T) {
+	req := &healthpb.HealthCheckRequest{Service: "helloworld.Greeter"}
+	res := &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}
+	tests := []struct {
+		name string
+		err  error
+		want Entry
+	}{
+		{
+			name: "RPC is logged with the message sizes",
+			want: Entry{
+				Protocol:   "grpc-web",
+				RPC:        "/grpc.health.v1.Health/Check",
+				Code:       codes.OK.String(),
+				BytesIn:    20,
+				BytesOut:   2,
+				RemoteAddr: "192.0.2.1:1234",
+				RequestID:  "4bf92f3577b34da6",
+			},
+		},
+		{
+			name: "Failed RPC is logged with the status code",
+			err:  status.Error(codes.NotFound, "unknown service"),
+			want: Entry{
+				Protocol:   "grpc-web",
+				RPC:        "/grpc.health.v1.Health/Check",
+				Code:       codes.NotFound.String(),
+				BytesIn:    20,
+				RemoteAddr: "192.0.2.1:1234",
+				RequestID:  "4bf92f3577b34da6",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			ctx := metrics.WithProtocol(context.Background(), metrics.ProtocolGRPCWeb)
+			ctx = requestid.WithID(ctx, "4bf92f3577b34da6")
+			ctx = peer.NewContext(ctx, &peer.Peer{
+				Addr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 1234},
+			})
+			_, err := New(config.AccessLog{}, logger.NewWriter(buf, logger.InfoLevel, false, false)).UnaryServerInterceptor()(ctx, req, &grpc.UnaryServerInfo{
+				FullMethod: "/grpc.health.v1.Health/Check",
+			}, func(context.Context, interface{}) (interface{}, error) {
+				if tt.err != nil {
+					return nil, tt.err
+				}
+				return res, nil
+			})
+			if err != tt.err {
+				t.Errorf("UnaryServerInterceptor() error = %v, want %v", err, tt.err)
+			}
+
+			var got Entry
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("UnaryServerInterceptor() log = %q, error = %v", buf.String(), err)
+			}
+			got.Time, got.LatencyMS = time.Time{}, 0
+			if got != tt.want {
+				t.Errorf("UnaryServerInterceptor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,2 @@
+// Package accesslog writes the structured access log of the REST, gRPC and gRPC-Web requests, as JSON or logfmt lines.
+package accesslog
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kpango/golang-server-template/devcert"
+	"github.com/pkg/errors"
+)
+
+const (
+	// certsCommand represents the subcommand which generates the certificates for the development
+	certsCommand = "certs"
+)
+
+type certsParams struct {
+	dir      string
+	hosts    string
+	clients  string
+	validFor time.Duration
+}
+
+func parseCertsParams(args []string) (*certsParams, error) {
+	p := new(certsParams)
+	f := flag.NewFlagSet(filepath.Base(os.Args[0])+" "+certsCommand, flag.ContinueOnError)
+	f.StringVar(&p.dir,
+		"dir",
+		"certs",
+		"directory which the certificates and the private keys are written to")
+	f.StringVar(&p.hosts,
+		"hosts",
+		strings.Join(devcert.DefaultHosts, ","),
+		"comma separated hostnames and IP addresses of the server certificate")
+	f.StringVar(&p.clients,
+		"clients",
+		"client",
+		"comma separated common names of the client certificates for the mutual TLS, empty generates no client certificate")
+	f.DurationVar(&p.validFor,
+		"valid-for",
+		devcert.DefaultValidFor,
+		"validity duration of the certificates")
+
+	err := f.Parse(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "Parse Failed")
+	}
+	return p, nil
+}
+
+// generateCerts writes the CA (ca.crt, ca.key), the server certificate (server.crt, server.key)
+// and the client certificates (<name>.crt, <name>.key) issued by the CA to p.dir, and returns the written certificate files.
+func generateCerts(p *certsParams) ([]string, error) {
+	ca, err := devcert.NewCA(devcert.CAName, p.validFor)
+	if err != nil {
+		return nil, err
+	}
+	server, err := ca.Server(split(p.hosts), p.validFor)
+	if err != nil {
+		return nil, err
+	}
+
+	kps := map[string]devcert.KeyPair{
+		"ca":     ca.KeyPair,
+		"server": server,
+	}
+	names := []string{"ca", "server"}
+	for _, cn := range split(p.clients) {
+		if _, ok := kps[cn]; ok {
+			return nil, errors.Errorf("client name %q collides with the CA or the server certificate", cn)
+		}
+		kp, err := ca.Client(cn, p.validFor)
+		if err != nil {
+			return nil, err
+		}
+		kps[cn] = kp
+		names = append(names, cn)
+	}
+
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		if err := kps[name].Write(p.dir, name); err != nil {
+			return nil, errors.Wrapf(err, "failed to write the %s certificate", name)
+		}
+		files = append(files, filepath.Join(p.dir, name+".crt"))
+	}
+	return files, nil
+}
+
+// split returns the non-empty trimmed values of the comma separated s.
+func split(s string) []string {
+	var vals []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
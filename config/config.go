@@ -5,15 +5,39 @@ and decode the configuration to a Config struct.
 package config
 
 import (
+	"fmt"
 	"os"
+	"reflect"
 	"strings"
 
-	yaml "gopkg.in/yaml.v2"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
 )
 
 const (
 	// currentVersion represent the config file version
-	currentVersion = "v1.0.0"
+	currentVersion = "v1.1.0"
+
+	// ModeProduction represent the production mode, which is the default mode
+	ModeProduction = "production"
+
+	// ModeDevelopment represent the development mode, which makes the programming errors fail loudly
+	ModeDevelopment = "development"
+
+	// ClientAuthNone represent the client certificate is not requested
+	ClientAuthNone = "none"
+
+	// ClientAuthRequest represent the client certificate is requested but not required nor verified
+	ClientAuthRequest = "request"
+
+	// ClientAuthRequireAny represent the client certificate is required but not verified
+	ClientAuthRequireAny = "require-any"
+
+	// ClientAuthVerifyIfGiven represent the client certificate is not required, but verified when it is sent
+	ClientAuthVerifyIfGiven = "verify-if-given"
+
+	// ClientAuthRequireAndVerify represent the client certificate is required and verified
+	ClientAuthRequireAndVerify = "require-and-verify"
 )
 
 // Config represent a application configuration content (config.yaml).
@@ -24,10 +48,20 @@ type Config struct {
 
 	// Server represent server and health check server configuration.
 	Server Server `yaml:"server"`
+
+	// Tracing represent the distributed tracing configuration.
+	Tracing Tracing `yaml:"tracing"`
+
+	// Logging represent the logging configuration.
+	Logging Logging `yaml:"logging"`
 }
 
 // Server represent server and health check server configuration.
 type Server struct {
+	// Mode represent the server mode, "production" or "development".
+	// The default mode is "production".
+	Mode string `yaml:"mode"`
+
 	// GrpcPort represent grpc API server port.
 	GrpcPort int `yaml:"grpc_port"`
 
@@ -40,9 +74,21 @@ type Server struct {
 	// HealthzPort represent health check server port for K8s.
 	HealthzPort int `yaml:"health_check_port"`
 
-	// HealthzPath represent the server path (pattern) for health check server.
+	// HealthzPath represent the server path (pattern) for the liveness probe of health check server.
 	HealthzPath string `yaml:"health_check_path"`
 
+	// ReadinessPath represent the server path (pattern) for the readiness probe of health check server.
+	// The default path is "/readiness".
+	ReadinessPath string `yaml:"readiness_check_path"`
+
+	// MetricsPort represent the Prometheus metrics server port.
+	// The metrics are served by the health check server when it is 0.
+	MetricsPort int `yaml:"metrics_port"`
+
+	// MetricsPath represent the server path (pattern) for the Prometheus metrics.
+	// The default path is "/metrics".
+	MetricsPath string `yaml:"metrics_path"`
+
 	// Timeout represent the server timeout value.
 	Timeout string `yaml:"timeout"`
 
@@ -52,8 +98,86 @@ type Server struct {
 	// ProbeWaitTime represent the parse duration between health check server and server shutdown.
 	ProbeWaitTime string `yaml:"probe_wait_time"`
 
+	// RequestIDHeader represent the header name which carries the request ID, it is the metadata key for gRPC.
+	// The default header is "X-Request-ID".
+	RequestIDHeader string `yaml:"request_id_header"`
+
 	// TLS represent the TLS configuration for server.
 	TLS TLS `yaml:"tls"`
+
+	// Listeners represent the per-listener configuration, which overrides the shared configuration of the REST, gRPC and gRPC-Web listeners,
+	// and configures the health check listener.
+	Listeners Listeners `yaml:"listeners"`
+}
+
+// Listeners represent the per-listener configuration of the API listeners and the health check listener.
+type Listeners struct {
+	// REST represent the configuration of the REST API listener.
+	REST Listener `yaml:"rest"`
+
+	// GRPC represent the configuration of the gRPC API listener.
+	GRPC Listener `yaml:"grpc"`
+
+	// GRPCWeb represent the configuration of the gRPC-Web API listener.
+	GRPCWeb Listener `yaml:"grpc_web"`
+
+	// HealthCheck represent the configuration of the health check listener.
+	// It does not share server.tls, since the K8s probes usually use plaintext, and it serves TLS only when its own TLS is enabled.
+	HealthCheck Listener `yaml:"health_check"`
+}
+
+// Listener represent the configuration of a listener.
+type Listener struct {
+	// TLS represent the TLS configuration of the listener, which replaces the shared server.tls when defined.
+	TLS *TLS `yaml:"tls"`
+
+	// H2C represent the listener serves HTTP/2 without TLS (h2c) in addition to HTTP/1.1 when TLS is disabled.
+	// It is not supported by the gRPC listener, which always serves HTTP/2.
+	H2C bool `yaml:"h2c"`
+}
+
+const (
+	// ListenerREST represents the name of the REST API listener
+	ListenerREST = "rest"
+
+	// ListenerGRPC represents the name of the gRPC API listener
+	ListenerGRPC = "grpc"
+
+	// ListenerGRPCWeb represents the name of the gRPC-Web API listener
+	ListenerGRPCWeb = "grpc_web"
+
+	// ListenerHealthCheck represents the name of the health check listener
+	ListenerHealthCheck = "health_check"
+)
+
+// ListenerNames represents the names of all listeners which have the per-listener configuration.
+var ListenerNames = []string{ListenerREST, ListenerGRPC, ListenerGRPCWeb, ListenerHealthCheck}
+
+// Listener returns the configuration of the named listener, ListenerREST, ListenerGRPC, ListenerGRPCWeb or ListenerHealthCheck.
+func (s Server) Listener(name string) Listener {
+	switch name {
+	case ListenerREST:
+		return s.Listeners.REST
+	case ListenerGRPC:
+		return s.Listeners.GRPC
+	case ListenerGRPCWeb:
+		return s.Listeners.GRPCWeb
+	case ListenerHealthCheck:
+		return s.Listeners.HealthCheck
+	}
+	return Listener{}
+}
+
+// ListenerTLS returns the TLS configuration of the named listener, which is the listener override or the shared server.tls.
+// The health check listener does not share server.tls, and its TLS is disabled unless it is defined.
+func (s Server) ListenerTLS(name string) TLS {
+	if t := s.Listener(name).TLS; t != nil {
+		return *t
+	}
+	if name == ListenerHealthCheck {
+		return TLS{}
+	}
+	return s.TLS
 }
 
 // TLS represent the TLS configuration for server.
@@ -61,6 +185,19 @@ type TLS struct {
 	// Enable represent the server enable TLS or not.
 	Enabled bool `yaml:"enabled"`
 
+	// Source represent where the certificate, the private key and the CA certificate are read from,
+	// "file", "env", "secret", "pkcs12", "dev" or "acme". The default source is "file".
+	//   "file": the files which paths are the environment variables of CertKey, KeyKey and CAKey.
+	//   "env": the inline PEM contents of the environment variables of CertKey, KeyKey and CAKey.
+	//   "secret": tls.crt, tls.key and the optional ca.crt in SecretDir, which is the layout of the mounted kubernetes.io/tls secret.
+	//   "pkcs12": the PKCS#12 bundle file which path is the environment variable of CertKey, decrypted by PKCS12Password,
+	//   and the CA certificate file which path is the environment variable of CAKey.
+	//   "dev": the server certificate issued by the CA generated on startup, which is defined by Dev and allowed only in the development mode,
+	//   and the CA certificate file of the client certificates which path is the environment variable of CAKey.
+	//   "acme": the certificates of the domains obtained and renewed by the ACME protocol as defined by ACME, which is supported by the REST and gRPC-Web listeners,
+	//   and the CA certificate file of the client certificates which path is the environment variable of CAKey.
+	Source string `yaml:"source"`
+
 	// CertKey represent the certificate environment variable key used to start server.
 	CertKey string `yaml:"cert_key"`
 
@@ -69,20 +206,316 @@ type TLS struct {
 
 	// CAKey represent the CA certificate environment variable key used to start server.
 	CAKey string `yaml:"ca_key"`
+
+	// SecretDir represent the directory of the mounted kubernetes.io/tls secret, which is used by the "secret" source.
+	SecretDir string `yaml:"secret_dir"`
+
+	// PKCS12Password represent the password of the PKCS#12 bundle, which is used by the "pkcs12" source.
+	PKCS12Password string `yaml:"pkcs12_password" secret:"true"`
+
+	// Dev represent the generated certificate, which is used by the "dev" source.
+	Dev DevCertificate `yaml:"dev"`
+
+	// ACME represent the certificate provisioning by the ACME protocol, which is used by the "acme" source.
+	ACME ACME `yaml:"acme"`
+
+	// ClientAuth represent the client authentication mode of the mutual TLS,
+	// "none", "request", "require-any", "verify-if-given" or "require-and-verify".
+	// The default mode is "require-and-verify" when the CA certificate is defined, otherwise "none".
+	ClientAuth string `yaml:"client_auth"`
+
+	// AllowedClientSubjects represent the patterns of the verified client certificate subject, e.g. "CN=client-*,O=example".
+	// The pattern is matched against the distinguished name and the common name, and "*" matches any sequence of characters.
+	AllowedClientSubjects []string `yaml:"allowed_client_subjects"`
+
+	// AllowedClientSANs represent the patterns of the verified client certificate subject alternative names,
+	// e.g. "*.example.com" or "spiffe://example.org/ns/*".
+	// The client certificate is accepted when it matches any of the subject or SAN patterns, and any verified certificate is accepted when both are empty.
+	AllowedClientSANs []string `yaml:"allowed_client_sans"`
+
+	// WatchInterval represent the interval to poll the certificate, private key and CA certificate files, which are reloaded when changed.
+	// The default interval is 10s, and "0s" disables the reload.
+	WatchInterval string `yaml:"watch_interval"`
+
+	// Certificates represent the additional certificates, which are selected by the SNI hostname of the client.
+	// The certificate of CertKey and KeyKey is served when none of them matches the hostname.
+	Certificates []Certificate `yaml:"certificates"`
+
+	// MinVersion represent the minimum TLS version, "1.0", "1.1", "1.2" or "1.3".
+	// The default version is "1.2".
+	MinVersion string `yaml:"min_version"`
+
+	// MaxVersion represent the maximum TLS version, "1.0", "1.1", "1.2" or "1.3".
+	// The default is the maximum version supported by Go.
+	MaxVersion string `yaml:"max_version"`
+
+	// CipherSuites represent the cipher suites of TLS 1.2 and earlier by the IANA name, e.g. "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256".
+	// The TLS 1.3 cipher suites are not configurable. The default is the secure cipher suites selected by Go.
+	CipherSuites []string `yaml:"cipher_suites"`
+
+	// CurvePreferences represent the elliptic curves in the preference order, "X25519", "P256", "P384" or "P521".
+	// The default order is "P521", "P384", "P256" and "X25519".
+	CurvePreferences []string `yaml:"curve_preferences"`
+}
+
+const (
+	// CertSourceFile represents the certificate source which reads the files
+	CertSourceFile = "file"
+
+	// CertSourceEnv represents the certificate source which reads the inline PEM from the environment variables
+	CertSourceEnv = "env"
+
+	// CertSourceSecret represents the certificate source which reads the directory of the kubernetes.io/tls secret
+	CertSourceSecret = "secret"
+
+	// CertSourcePKCS12 represents the certificate source which reads the PKCS#12 bundle
+	CertSourcePKCS12 = "pkcs12"
+
+	// CertSourceDev represents the certificate source which generates the CA and the server certificate for the development
+	CertSourceDev = "dev"
+
+	// CertSourceACME represents the certificate source which obtains the certificates by the ACME protocol
+	CertSourceACME = "acme"
+)
+
+var (
+	// ACMEListenerNames represents the listeners supporting the "acme" certificate source, which serve HTTPS to the browsers
+	ACMEListenerNames = []string{ListenerREST, ListenerGRPCWeb}
+
+	// TLSVersions represents the supported TLS version names of TLS.MinVersion and TLS.MaxVersion in the ascending order.
+	TLSVersions = []string{"1.0", "1.1", "1.2", "1.3"}
+
+	// TLSCurves represents the supported elliptic curve names of TLS.CurvePreferences.
+	TLSCurves = []string{"X25519", "P256", "P384", "P521"}
+)
+
+// Certificate represent an additional certificate selected by the SNI hostname, which is read from the same source as TLS.
+type Certificate struct {
+	// CertKey represent the certificate environment variable key.
+	CertKey string `yaml:"cert_key"`
+
+	// KeyKey represent the private key environment variable key.
+	KeyKey string `yaml:"key_key"`
+
+	// SecretDir represent the directory of the mounted kubernetes.io/tls secret.
+	SecretDir string `yaml:"secret_dir"`
+}
+
+// DevCertificate represent the server certificate generated in the development mode, which is issued by the CA generated on startup.
+type DevCertificate struct {
+	// Hosts represent the hostnames and the IP addresses of the server certificate.
+	// The default hosts are "localhost", "127.0.0.1" and "::1".
+	Hosts []string `yaml:"hosts"`
+
+	// Dir represent the directory which the CA certificate (ca.crt), the server certificate (server.crt) and its private key (server.key) are written to,
+	// so that the clients can trust the CA. The certificates are not written when it is empty.
+	Dir string `yaml:"dir"`
+
+	// ValidFor represent the validity duration of the generated certificates.
+	// The default duration is 8760h.
+	ValidFor string `yaml:"valid_for"`
+}
+
+// ACME represent the certificate provisioning by the ACME protocol.
+// The certificate of a domain is obtained on the first TLS handshake of it, and renewed before it expires.
+// The domain is validated by the TLS-ALPN-01 challenge served by the TLS listener, and also by the HTTP-01 challenge when HTTPPort is defined.
+type ACME struct {
+	// Domains represent the domains which the certificates are obtained for, the handshakes of the other server names are rejected.
+	Domains []string `yaml:"domains"`
+
+	// DirectoryURL represent the directory URL of the ACME server (RFC 8555), e.g. the one of the local test server.
+	// The default is the production directory of Let's Encrypt, https://acme-v02.api.letsencrypt.org/directory.
+	DirectoryURL string `yaml:"directory_url"`
+
+	// CacheDir represent the directory which the account key and the obtained certificates are cached in, so that they are reused across the restarts.
+	CacheDir string `yaml:"cache_dir"`
+
+	// Email represent the contact email address of the ACME account, which is notified about the problems of the certificates.
+	Email string `yaml:"email"`
+
+	// HTTPPort represent the port of the HTTP-01 challenge server, which must be reachable as the port 80 of the domains.
+	// The HTTP-01 challenge is disabled when it is 0.
+	HTTPPort int `yaml:"http_port"`
+
+	// RenewBefore represent how early the certificates are renewed before they expire.
+	// The default duration is 720h.
+	RenewBefore string `yaml:"renew_before"`
+}
+
+// Tracing represent the distributed tracing configuration.
+type Tracing struct {
+	// Enabled represent the server starts the spans of REST requests and RPCs, and exports them or not.
+	Enabled bool `yaml:"enabled"`
+
+	// ServiceName represent the service name recorded in every span.
+	// The default name is "server".
+	ServiceName string `yaml:"service_name"`
+
+	// SamplingRatio represent the ratio of the traces started by the server to be exported, between 0 and 1.
+	// The sampling decision of the caller is respected for the propagated traces. The default ratio 0 is treated as 1.
+	SamplingRatio float64 `yaml:"sampling_ratio"`
+
+	// Exporter represent the span exporter, "stdout", "file" or "otlp".
+	// The default exporter is "stdout".
+	Exporter string `yaml:"exporter"`
+
+	// FilePath represent the file which the "file" exporter appends the spans to, as JSON lines.
+	FilePath string `yaml:"file_path"`
+
+	// OTLP represent the configuration of the "otlp" exporter.
+	OTLP OTLP `yaml:"otlp"`
+}
+
+// OTLP represent the OTLP/HTTP exporter configuration.
+type OTLP struct {
+	// Endpoint represent the URL which the spans are posted to, e.g. "http://localhost:4318/v1/traces".
+	Endpoint string `yaml:"endpoint"`
+
+	// Headers represent the additional HTTP headers sent with the spans, in "Key=Value" form.
+	Headers []string `yaml:"headers" secret:"true"`
+
+	// Timeout represent the timeout of a export request.
+	// The default timeout is 10s.
+	Timeout string `yaml:"timeout"`
 }
 
-// New returns *Config or error when decode the configuration file to actually *Config struct.
-func New(path string) (*Config, error) {
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+// Logging represent the logging configuration.
+type Logging struct {
+	// Level represent the minimum level of the log lines, "debug", "info", "warn" or "error".
+	// The default level is "info". It can be changed at runtime through the admin endpoint.
+	Level string `yaml:"level"`
+
+	// Format represent the format of the log lines, "text" or "json".
+	// The default format is "text".
+	Format string `yaml:"format"`
+
+	// Output represent the destination of the log lines and the access log, "stdout" or "file".
+	// The default output is "stdout".
+	Output string `yaml:"output"`
+
+	// File represent the configuration of the "file" output.
+	File LogFile `yaml:"file"`
+
+	// Color represent the level tags of the text lines are colored or not.
+	Color bool `yaml:"color"`
+
+	// LevelPath represent the server path (pattern) of the admin endpoint of the log level, served by the health check server.
+	// The default path is "/loglevel".
+	LevelPath string `yaml:"level_path"`
+
+	// Access represent the access log configuration.
+	Access AccessLog `yaml:"access"`
+}
+
+// LogFile represent the configuration of the rotated log file.
+type LogFile struct {
+	// Path represent the file which the log lines are appended to.
+	Path string `yaml:"path"`
+
+	// MaxSizeMB represent the size in megabytes which the file is rotated at.
+	// The default size is 100.
+	MaxSizeMB int `yaml:"max_size_mb"`
+
+	// MaxBackups represent the number of the rotated files kept, "<path>.1" is the newest.
+	// The default number is 3.
+	MaxBackups int `yaml:"max_backups"`
+}
+
+// AccessLog represent the access log configuration of the REST, gRPC and gRPC-Web requests.
+type AccessLog struct {
+	// Enabled represent the server writes the access log to the log output or not.
+	Enabled bool `yaml:"enabled"`
+
+	// Format represent the format of the access log lines, "json" or "logfmt".
+	// The default format is "json".
+	Format string `yaml:"format"`
+
+	// SamplingRatio represent the ratio of the requests to be logged, between 0 and 1.
+	// The failed requests are always logged. The default ratio 0 is treated as 1.
+	SamplingRatio float64 `yaml:"sampling_ratio"`
+
+	// ExcludePaths represent the request paths and the gRPC full method names which are not logged,
+	// e.g. "/healthz" or "/grpc.health.v1.Health/Check".
+	ExcludePaths []string `yaml:"exclude_paths"`
+}
+
+// New returns *Config or error when decode the configuration files to actually *Config struct.
+// It is Load without the migration notes.
+func New(paths ...string) (*Config, error) {
+	cfg, _, err := Load(paths...)
+	return cfg, err
+}
+
+// Load returns *Config or error when decode the configuration files to actually *Config struct,
+// and the notes of the migrations applied to the files, which are meant to be logged.
+// The format of each file is detected from its extension (YAML, JSON or TOML), and the files are deep merged in order,
+// so that the later file overrides the fields defined in the former files.
+// The configuration file of an older version is migrated to the current version before merging.
+// It returns error when any of the files does not exist, or defines a key which is unknown to the Config struct,
+// the error reports the line and column of every unknown key.
+// After decoding, every field can be overridden by the environment variable named by EnvName of its yaml field path,
+// which has EnvPrefix, e.g. APP_SERVER_HTTP_PORT overrides server.http_port.
+// And then the "_VAR_" indirection is applied to all string fields, e.g. "_TLS_CERT_" is replaced by the value of TLS_CERT,
+// which is the variable named in the configuration without EnvPrefix.
+func Load(paths ...string) (*Config, []string, error) {
+	raw := make(map[string]interface{})
+	var errs ValidationError
+	var notes []string
+	for _, path := range paths {
+		src, pos, err := load(path)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to load configuration %s", path)
+		}
+
+		// the overlay file is allowed to omit the version, it is treated as the current version
+		if _, ok := src["version"]; ok {
+			ns, err := Migrate(src)
+			if err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to load configuration %s", path)
+			}
+			for _, n := range ns {
+				notes = append(notes, fmt.Sprintf("configuration %s migrated: %s", path, n))
+			}
+		}
+
+		for _, field := range unknownFields("", src, reflect.TypeOf(Config{})) {
+			errs = append(errs, &FieldError{
+				Field:   field,
+				Message: "unknown field",
+				File:    path,
+				Line:    pos[field].line,
+				Column:  pos[field].column,
+			})
+		}
+
+		merge(raw, src)
+	}
+	if len(errs) > 0 {
+		return nil, nil, errs
+	}
+
+	_, err := Migrate(raw)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
 	}
 	cfg := new(Config)
-	err = yaml.NewDecoder(f).Decode(&cfg)
+	err = yaml.Unmarshal(b, cfg)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return cfg, nil
+
+	err = overlayEnv(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	expandActualValue(cfg)
+
+	return cfg, notes, nil
 }
 
 // GetVersion returns the current version of the server version.
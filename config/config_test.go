@@ -12,7 +12,7 @@ func TestGetVersion(t *testing.T) {
 	}{
 		{
 			name: "Test get version return server version",
-			want: "v1.0.0",
+			want: "v1.1.0",
 		},
 	}
 	for _, tt := range tests {
@@ -128,3 +128,16 @@ func TestCheckPrefixAndSuffix(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_sampleConfig(t *testing.T) {
+	cfg, notes, err := Load("../service/assets/sample_config.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Version != currentVersion || len(notes) != 0 {
+		t.Errorf("Load() version = %v, notes = %v, want the current version %v without migration", cfg.Version, notes, currentVersion)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
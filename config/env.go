@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix represents the prefix of the environment variables which override the configuration fields,
+// so that the generic variables set by the platform, e.g. VERSION or SERVER_PORT, never override the configuration silently.
+// It is changed before the configuration is loaded, e.g. by the -env-prefix flag.
+var EnvPrefix = "APP_"
+
+// EnvName returns the environment variable name which overrides the configuration field of the yaml field path.
+// For example, "server.http_port" is overridden by APP_SERVER_HTTP_PORT with the default EnvPrefix.
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(path))
+}
+
+// overlayEnv overrides every field of the struct pointed by v by the environment variable derived from its yaml tag path.
+// The nested struct fields are overridden recursively, for example Config.Server.TLS.Enabled is overridden by APP_SERVER_TLS_ENABLED.
+// The nil pointer to a struct, e.g. Config.Server.Listeners.REST.TLS, is allocated only when any of its fields is overridden.
+func overlayEnv(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("cannot overlay environment variables on %T", v)
+	}
+	_, err := overlayStruct("", rv.Elem())
+	return err
+}
+
+// overlayStruct overrides the fields of the struct rv, and returns true when any of them is overridden.
+func overlayStruct(prefix string, rv reflect.Value) (bool, error) {
+	var overridden bool
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name := yamlName(rt.Field(i))
+		if name == "" {
+			continue
+		}
+		path := join(prefix, name)
+		fv := rv.Field(i)
+
+		switch {
+		case fv.Kind() == reflect.Struct:
+			ok, err := overlayStruct(path, fv)
+			if err != nil {
+				return false, err
+			}
+			overridden = overridden || ok
+			continue
+		case fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct:
+			// the nil pointer is overlaid on a new struct, which is kept only when it is overridden
+			pv := fv
+			if fv.IsNil() {
+				pv = reflect.New(fv.Type().Elem())
+			}
+			ok, err := overlayStruct(path, pv.Elem())
+			if err != nil {
+				return false, err
+			}
+			if ok && fv.IsNil() {
+				fv.Set(pv)
+			}
+			overridden = overridden || ok
+			continue
+		}
+
+		env := EnvName(path)
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, val); err != nil {
+			return false, errors.Wrapf(err, "invalid environment variable %s for %s", env, path)
+		}
+		overridden = true
+	}
+	return overridden, nil
+}
+
+// setValue parses val according to the kind of rv and sets it.
+// A slice of strings is parsed as a comma separated list.
+func setValue(rv reflect.Value, val string) error {
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, rv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		rv.SetFloat(f)
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported type %s", rv.Type())
+		}
+		var vals []string
+		if val != "" {
+			vals = strings.Split(val, ",")
+		}
+		sv := reflect.MakeSlice(rv.Type(), len(vals), len(vals))
+		for i, v := range vals {
+			sv.Index(i).SetString(strings.TrimSpace(v))
+		}
+		rv.Set(sv)
+	default:
+		return errors.Errorf("unsupported type %s", rv.Type())
+	}
+	return nil
+}
+
+// expandActualValue replaces every string value reachable from v by GetActualValue recursively,
+// so that the "_VAR_" indirection is applied to all string fields.
+// The variable of the indirection is named by the configuration as it is, EnvPrefix is not prepended to it.
+func expandActualValue(v interface{}) {
+	expandValue(reflect.ValueOf(v))
+}
+
+func expandValue(rv reflect.Value) {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if !rv.IsNil() {
+			expandValue(rv.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).PkgPath == "" {
+				expandValue(rv.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			expandValue(rv.Index(i))
+		}
+	case reflect.String:
+		if rv.CanSet() {
+			rv.SetString(GetActualValue(rv.String()))
+		}
+	}
+}
+
+// yamlName returns the yaml key name of the struct field, or empty when the field is not decoded from yaml.
+func yamlName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	tag := f.Tag.Get("yaml")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		prefix string
+		path   string
+		want   string
+	}{
+		{
+			prefix: "APP_",
+			path:   "version",
+			want:   "APP_VERSION",
+		},
+		{
+			prefix: "APP_",
+			path:   "server.http_port",
+			want:   "APP_SERVER_HTTP_PORT",
+		},
+		{
+			prefix: "MYSERVER_",
+			path:   "server.tls.enabled",
+			want:   "MYSERVER_SERVER_TLS_ENABLED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			defer func(prefix string) {
+				EnvPrefix = prefix
+			}(EnvPrefix)
+			EnvPrefix = tt.prefix
+
+			if got := EnvName(tt.path); got != tt.want {
+				t.Errorf("EnvName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_overlayEnv(t *testing.T) {
+	type nested struct {
+		Names []string `yaml:"names"`
+		Ratio float64  `yaml:"ratio"`
+	}
+	type target struct {
+		Port    int     `yaml:"port"`
+		Enabled bool    `yaml:"enabled"`
+		Name    string  `yaml:"name"`
+		Ignored string  `yaml:"-"`
+		Nested  nested  `yaml:"nested"`
+		Pointer *nested `yaml:"pointer"`
+	}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    target
+		wantErr bool
+	}{
+		{
+			name: "Unset environment variables keep the decoded value",
+			want: target{
+				Port: 80,
+				Name: "decoded",
+			},
+		},
+		{
+			name: "Every kind of field is overridden",
+			env: map[string]string{
+				"APP_PORT":         "443",
+				"APP_ENABLED":      "true",
+				"APP_NAME":         "",
+				"APP_IGNORED":      "ignored",
+				"APP_NESTED_NAMES": "a, b",
+				"APP_NESTED_RATIO": "0.5",
+			},
+			want: target{
+				Port:    443,
+				Enabled: true,
+				Nested: nested{
+					Names: []string{"a", "b"},
+					Ratio: 0.5,
+				},
+			},
+		},
+		{
+			name: "Variables without the prefix are ignored",
+			env: map[string]string{
+				"PORT":    "443",
+				"ENABLED": "true",
+			},
+			want: target{
+				Port: 80,
+				Name: "decoded",
+			},
+		},
+		{
+			name: "Nil pointer to struct is allocated when any of its fields is overridden",
+			env: map[string]string{
+				"APP_POINTER_RATIO": "1.5",
+			},
+			want: target{
+				Port:    80,
+				Name:    "decoded",
+				Pointer: &nested{Ratio: 1.5},
+			},
+		},
+		{
+			name: "Invalid value returns error",
+			env: map[string]string{
+				"APP_PORT": "https",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid value of the pointer to struct returns error",
+			env: map[string]string{
+				"APP_POINTER_RATIO": "half",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+			}
+			defer func() {
+				for k := range tt.env {
+					os.Unsetenv(k)
+				}
+			}()
+
+			got := target{
+				Port: 80,
+				Name: "decoded",
+			}
+			err := overlayEnv(&got)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("overlayEnv() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("overlayEnv() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_expandActualValue(t *testing.T) {
+	os.Setenv("expand_test_value", "expanded")
+	defer os.Unsetenv("expand_test_value")
+
+	got := Config{
+		Version: "_expand_test_value_",
+		Server: Server{
+			HealthzPath: "/healthz",
+			TLS: TLS{
+				CertKey: "_expand_test_value_",
+			},
+		},
+	}
+	expandActualValue(&got)
+
+	if got.Version != "expanded" || got.Server.TLS.CertKey != "expanded" {
+		t.Errorf("expandActualValue() = %+v, want expanded values", got)
+	}
+	if got.Server.HealthzPath != "/healthz" {
+		t.Errorf("expandActualValue() health_check_path = %v, want %v", got.Server.HealthzPath, "/healthz")
+	}
+}
+
+func TestNew_overlayEnv(t *testing.T) {
+	env := map[string]string{
+		"APP_SERVER_HTTP_PORT":    "8443",
+		"APP_SERVER_TLS_ENABLED":  "false",
+		"APP_SERVER_TLS_CERT_KEY": "_overlay_test_cert_",
+		"overlay_test_cert":       "TLS_CERT",
+
+		"APP_SERVER_LISTENERS_REST_TLS_ENABLED":     "true",
+		"APP_SERVER_LISTENERS_REST_TLS_MIN_VERSION": "1.3",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+	}
+	defer func() {
+		for k := range env {
+			os.Unsetenv(k)
+		}
+	}()
+
+	cfg, err := New("../service/assets/sample_config.yaml")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if cfg.Server.RestPort != 8443 {
+		t.Errorf("New() http_port = %v, want %v", cfg.Server.RestPort, 8443)
+	}
+	if cfg.Server.TLS.Enabled {
+		t.Errorf("New() tls.enabled = %v, want %v", cfg.Server.TLS.Enabled, false)
+	}
+	if cfg.Server.TLS.CertKey != "TLS_CERT" {
+		t.Errorf("New() tls.cert_key = %v, want %v", cfg.Server.TLS.CertKey, "TLS_CERT")
+	}
+	if l := cfg.Server.Listeners.REST.TLS; l == nil || !l.Enabled || l.MinVersion != "1.3" {
+		t.Errorf("New() listeners.rest.tls = %+v, want the enabled TLS 1.3 override", l)
+	}
+	if l := cfg.Server.Listeners.GRPC.TLS; l != nil {
+		t.Errorf("New() listeners.grpc.tls = %+v, want nil", l)
+	}
+}
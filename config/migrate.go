@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// migration represents a schema migration step, which rewrites a raw configuration of the version from into the version to.
+type migration struct {
+	from string
+	to   string
+
+	// migrate rewrites the raw configuration in place, and returns the description of each rewrite.
+	migrate func(raw map[string]interface{}) ([]string, error)
+}
+
+var (
+	// migrations represents the migration registry keyed by the source version.
+	// To change the configuration schema, bump currentVersion and register the migration from the previous version here.
+	migrations = newMigrations(
+		migration{
+			from:    "v1.0.0",
+			to:      "v1.1.0",
+			migrate: migrateLegacyPort,
+		},
+	)
+
+	// ErrVersionNotFound represents an error that the configuration file does not define the version
+	ErrVersionNotFound = errors.New("configuration version not found")
+)
+
+// newMigrations returns the migration registry of ms.
+func newMigrations(ms ...migration) map[string]migration {
+	r := make(map[string]migration, len(ms))
+	for _, m := range ms {
+		r[m.from] = m
+	}
+	return r
+}
+
+// Migrate upgrades the raw configuration to the current version step by step, and returns the description of each rewrite.
+// It returns error when the version is newer than the current version, or there is no migration path to the current version.
+func Migrate(raw map[string]interface{}) ([]string, error) {
+	ver, ok := raw["version"].(string)
+	if !ok || ver == "" {
+		return nil, ErrVersionNotFound
+	}
+
+	var notes []string
+	for ver != currentVersion {
+		cmp, err := compareVersion(ver, currentVersion)
+		if err != nil {
+			return nil, err
+		}
+		if cmp > 0 {
+			return nil, errors.Errorf("configuration version %s is newer than the supported version %s", ver, currentVersion)
+		}
+
+		m, ok := migrations[ver]
+		if !ok {
+			return nil, errors.Errorf("no migration path from configuration version %s to %s", ver, currentVersion)
+		}
+
+		ns, err := m.migrate(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to migrate configuration version %s to %s", m.from, m.to)
+		}
+		for _, n := range ns {
+			notes = append(notes, fmt.Sprintf("%s -> %s: %s", m.from, m.to, n))
+		}
+
+		raw["version"] = m.to
+		ver = m.to
+	}
+
+	return notes, nil
+}
+
+// migrateLegacyPort renames the legacy flat server.port key to server.http_port.
+func migrateLegacyPort(raw map[string]interface{}) ([]string, error) {
+	srv, ok := raw["server"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	port, ok := srv["port"]
+	if !ok {
+		return nil, nil
+	}
+	delete(srv, "port")
+
+	if _, ok := srv["http_port"]; ok {
+		return []string{"removed server.port, server.http_port is already defined"}, nil
+	}
+	srv["http_port"] = port
+	return []string{"renamed server.port to server.http_port"}, nil
+}
+
+// compareVersion returns -1, 0 or 1 when the version a is older than, equal to or newer than b.
+// The version format is "vMAJOR.MINOR.PATCH".
+func compareVersion(a, b string) (int, error) {
+	av, err := parseVersion(a)
+	if err != nil {
+		return 0, err
+	}
+	bv, err := parseVersion(b)
+	if err != nil {
+		return 0, err
+	}
+	for i := range av {
+		switch {
+		case av[i] < bv[i]:
+			return -1, nil
+		case av[i] > bv[i]:
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// parseVersion returns the major, minor and patch number of the version.
+func parseVersion(ver string) ([3]int, error) {
+	var v [3]int
+	parts := strings.Split(strings.TrimPrefix(ver, "v"), ".")
+	if !strings.HasPrefix(ver, "v") || len(parts) != len(v) {
+		return v, errors.Errorf("invalid configuration version %q", ver)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, errors.Errorf("invalid configuration version %q", ver)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
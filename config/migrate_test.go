@@ -0,0 +1,127 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       map[string]interface{}
+		want      map[string]interface{}
+		wantNotes int
+		wantErr   bool
+	}{
+		{
+			name: "Current version is not migrated",
+			raw: map[string]interface{}{
+				"version": currentVersion,
+				"server": map[string]interface{}{
+					"port": 443,
+				},
+			},
+			want: map[string]interface{}{
+				"version": currentVersion,
+				"server": map[string]interface{}{
+					"port": 443,
+				},
+			},
+		},
+		{
+			name: "Legacy port is renamed to http_port",
+			raw: map[string]interface{}{
+				"version": "v1.0.0",
+				"server": map[string]interface{}{
+					"port":              443,
+					"health_check_port": 8080,
+				},
+			},
+			want: map[string]interface{}{
+				"version": currentVersion,
+				"server": map[string]interface{}{
+					"http_port":         443,
+					"health_check_port": 8080,
+				},
+			},
+			wantNotes: 1,
+		},
+		{
+			name: "Legacy port does not override http_port",
+			raw: map[string]interface{}{
+				"version": "v1.0.0",
+				"server": map[string]interface{}{
+					"port":      443,
+					"http_port": 8443,
+				},
+			},
+			want: map[string]interface{}{
+				"version": currentVersion,
+				"server": map[string]interface{}{
+					"http_port": 8443,
+				},
+			},
+			wantNotes: 1,
+		},
+		{
+			name: "Newer version is rejected",
+			raw: map[string]interface{}{
+				"version": "v99.0.0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Version without migration path is rejected",
+			raw: map[string]interface{}{
+				"version": "v0.9.0",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "Missing version is rejected",
+			raw:     map[string]interface{}{},
+			wantErr: true,
+		},
+		{
+			name: "Invalid version is rejected",
+			raw: map[string]interface{}{
+				"version": "latest",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notes, err := Migrate(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrate() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if len(notes) != tt.wantNotes {
+				t.Errorf("Migrate() notes = %v, want %d notes", notes, tt.wantNotes)
+			}
+			if !reflect.DeepEqual(tt.raw, tt.want) {
+				t.Errorf("Migrate() = %v, want %v", tt.raw, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_migrate(t *testing.T) {
+	cfg, err := New("testdata/v1.0.0_config.yaml")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if cfg.Version != currentVersion {
+		t.Errorf("New() version = %v, want %v", cfg.Version, currentVersion)
+	}
+	if cfg.Server.RestPort != 443 {
+		t.Errorf("New() http_port = %v, want %v", cfg.Server.RestPort, 443)
+	}
+	if cfg.Server.HealthzPath != "/healthz" {
+		t.Errorf("New() health_check_path = %v, want %v", cfg.Server.HealthzPath, "/healthz")
+	}
+}
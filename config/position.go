@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// position represents the line and column of a key in a configuration file, both start from 1.
+type position struct {
+	line   int
+	column int
+}
+
+// yamlPositions returns the positions of every mapping key in the yaml document node, keyed by the field path.
+func yamlPositions(n *yaml.Node) map[string]position {
+	pos := make(map[string]position)
+	var walk func(path string, n *yaml.Node)
+	walk = func(path string, n *yaml.Node) {
+		switch n.Kind {
+		case yaml.DocumentNode:
+			for _, c := range n.Content {
+				walk(path, c)
+			}
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := join(path, n.Content[i].Value)
+				pos[k] = position{
+					line:   n.Content[i].Line,
+					column: n.Content[i].Column,
+				}
+				walk(k, n.Content[i+1])
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(fmt.Sprintf("%s[%d]", path, i), c)
+			}
+		}
+	}
+	walk("", n)
+	return pos
+}
+
+// jsonPositions returns the positions of every object key in the json document, keyed by the field path.
+func jsonPositions(b []byte) map[string]position {
+	type frame struct {
+		path     string
+		object   bool
+		key      string
+		expected bool
+		index    int
+	}
+
+	pos := make(map[string]position)
+	dec := json.NewDecoder(bytes.NewReader(b))
+	stack := []*frame{{}}
+	for {
+		off := int(dec.InputOffset())
+		tok, err := dec.Token()
+		if err != nil {
+			return pos
+		}
+		top := stack[len(stack)-1]
+
+		// valuePath returns the path of the value which starts with the current token
+		valuePath := func() string {
+			if top.object {
+				return join(top.path, top.key)
+			}
+			if len(stack) == 1 {
+				return ""
+			}
+			return fmt.Sprintf("%s[%d]", top.path, top.index)
+		}
+		// done marks the value of the top frame is consumed
+		done := func(f *frame) {
+			if f.object {
+				f.expected = true
+				return
+			}
+			f.index++
+		}
+
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				stack = append(stack, &frame{
+					path:     valuePath(),
+					object:   t == '{',
+					expected: t == '{',
+				})
+			default:
+				stack = stack[:len(stack)-1]
+				done(stack[len(stack)-1])
+			}
+		case string:
+			if top.object && top.expected {
+				for off < len(b) && strings.IndexByte(" \t\r\n,", b[off]) >= 0 {
+					off++
+				}
+				top.key = t
+				top.expected = false
+				pos[join(top.path, t)] = offsetPosition(b, off)
+				continue
+			}
+			done(top)
+		default:
+			done(top)
+		}
+	}
+}
+
+// tomlPositions returns the positions of every table header and key in the toml document, keyed by the field path.
+// The multi-line values are not parsed, it is enough to find the position of a key.
+func tomlPositions(b []byte) map[string]position {
+	pos := make(map[string]position)
+	table := ""
+	for i, line := range strings.Split(string(b), "\n") {
+		t := strings.TrimSpace(line)
+		if t == "" || strings.HasPrefix(t, "#") {
+			continue
+		}
+		p := position{
+			line:   i + 1,
+			column: strings.Index(line, t) + 1,
+		}
+		if strings.HasPrefix(t, "[") {
+			if end := strings.Index(t, "]"); end > 0 {
+				table = tomlKey(strings.Trim(t[:end], "[]"))
+				pos[table] = p
+			}
+			continue
+		}
+		if eq := strings.Index(t, "="); eq > 0 {
+			pos[join(table, tomlKey(t[:eq]))] = p
+		}
+	}
+	return pos
+}
+
+// tomlKey returns the dotted field path of the toml key, the quotes of each part are removed.
+func tomlKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(p), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+// offsetPosition returns the position of the byte offset in b.
+func offsetPosition(b []byte, off int) position {
+	line := bytes.Count(b[:off], []byte("\n")) + 1
+	return position{
+		line:   line,
+		column: off - bytes.LastIndexByte(b[:off], '\n'),
+	}
+}
+
+// unknownFields returns the sorted field paths in raw, which are not defined in the struct type t.
+func unknownFields(prefix string, raw map[string]interface{}, t reflect.Type) []string {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "" {
+			fields[name] = t.Field(i).Type
+		}
+	}
+
+	var unknown []string
+	for k, v := range raw {
+		path := join(prefix, k)
+		ft, ok := fields[k]
+		if !ok {
+			unknown = append(unknown, path)
+			continue
+		}
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		switch {
+		case ft.Kind() == reflect.Struct:
+			if m, ok := v.(map[string]interface{}); ok {
+				unknown = append(unknown, unknownFields(path, m, ft)...)
+			}
+		case ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Struct:
+			if s, ok := v.([]interface{}); ok {
+				for i, e := range s {
+					if m, ok := e.(map[string]interface{}); ok {
+						unknown = append(unknown, unknownFields(fmt.Sprintf("%s[%d]", path, i), m, ft.Elem())...)
+					}
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
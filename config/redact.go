@@ -0,0 +1,60 @@
+package config
+
+import (
+	"io"
+	"reflect"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	// redactedValue represents the value shown instead of the secret field value
+	redactedValue = "<redacted>"
+)
+
+// Redacted returns a copy of the configuration, which every non-empty string field, or string slice element, tagged with `secret:"true"` is replaced by the redacted value.
+func (c Config) Redacted() Config {
+	redact(reflect.ValueOf(&c).Elem())
+	return c
+}
+
+// Dump writes the configuration to w as yaml, the secret fields are redacted.
+func (c Config) Dump(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	defer enc.Close()
+	return enc.Encode(c.Redacted())
+}
+
+func redact(rv reflect.Value) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			redact(fv)
+		case fv.Kind() == reflect.Ptr && !fv.IsNil() && fv.Elem().Kind() == reflect.Struct:
+			// the struct is copied, so that the redacted fields are not shared with the original configuration
+			p := reflect.New(fv.Elem().Type())
+			p.Elem().Set(fv.Elem())
+			redact(p.Elem())
+			fv.Set(p)
+		case f.Tag.Get("secret") == "true" && fv.Kind() == reflect.String && fv.Len() > 0:
+			fv.SetString(redactedValue)
+		case f.Tag.Get("secret") == "true" && fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.String:
+			// the slice is copied, so that the redacted elements are not shared with the original configuration
+			s := reflect.MakeSlice(fv.Type(), fv.Len(), fv.Len())
+			for j := 0; j < fv.Len(); j++ {
+				s.Index(j).SetString(fv.Index(j).String())
+				if fv.Index(j).Len() > 0 {
+					s.Index(j).SetString(redactedValue)
+				}
+			}
+			fv.Set(s)
+		}
+	}
+}
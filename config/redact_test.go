@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_redact(t *testing.T) {
+	type inner struct {
+		Password string `secret:"true"`
+		Empty    string `secret:"true"`
+		User     string
+	}
+	type outer struct {
+		Token   string   `secret:"true"`
+		Headers []string `secret:"true"`
+		Inner   inner
+		Ptr     *inner
+		Nil     *inner
+	}
+
+	headers := []string{"Authorization=Bearer token", ""}
+	ptr := &inner{Password: "password"}
+	got := outer{
+		Token:   "token",
+		Headers: headers,
+		Inner: inner{
+			Password: "password",
+			User:     "user",
+		},
+		Ptr: ptr,
+	}
+	redact(reflect.ValueOf(&got).Elem())
+
+	want := outer{
+		Token:   redactedValue,
+		Headers: []string{redactedValue, ""},
+		Inner: inner{
+			Password: redactedValue,
+			User:     "user",
+		},
+		Ptr: &inner{Password: redactedValue},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("redact() = %+v, want %+v", got, want)
+	}
+	if headers[0] != "Authorization=Bearer token" {
+		t.Errorf("redact() modified the original slice = %v", headers)
+	}
+	if ptr.Password != "password" {
+		t.Errorf("redact() modified the original struct = %+v", ptr)
+	}
+}
+
+func TestConfig_Dump(t *testing.T) {
+	cfg := validConfig()
+
+	buf := new(bytes.Buffer)
+	if err := cfg.Dump(buf); err != nil {
+		t.Fatalf("Dump() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "http_port: 8081") {
+		t.Errorf("Dump() = %s, want http_port", buf.String())
+	}
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Watch polls the configuration files every interval, and sends a notification to the returned channel whenever any of the file contents is changed.
+// The contents are compared instead of the modification time, so that the K8s ConfigMap update, which swaps the symbolic link of the mounted file, is also detected.
+// The path of a directory watches the files in it, so that the files added to or removed from it are also detected.
+// The returned channel is closed when the ctx is done.
+func Watch(ctx context.Context, interval time.Duration, paths ...string) <-chan struct{} {
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer close(ch)
+
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+
+		prev := checksum(paths...)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				cur := checksum(paths...)
+				if cur == nil || bytes.Equal(prev, cur) {
+					continue
+				}
+				prev = cur
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// RestartRequired returns the yaml field paths which differ between old and new configuration, and cannot be applied to the running server without restart.
+func RestartRequired(old, new Config) []string {
+	fields := make([]string, 0, 5)
+	if old.Version != new.Version {
+		fields = append(fields, "version")
+	}
+	if old.Server.Mode != new.Server.Mode {
+		fields = append(fields, "server.mode")
+	}
+	if old.Server.GrpcPort != new.Server.GrpcPort {
+		fields = append(fields, "server.grpc_port")
+	}
+	if old.Server.GrpcWebPort != new.Server.GrpcWebPort {
+		fields = append(fields, "server.grpc_web_port")
+	}
+	if old.Server.RestPort != new.Server.RestPort {
+		fields = append(fields, "server.http_port")
+	}
+	if old.Server.HealthzPort != new.Server.HealthzPort {
+		fields = append(fields, "server.health_check_port")
+	}
+	if old.Server.HealthzPath != new.Server.HealthzPath {
+		fields = append(fields, "server.health_check_path")
+	}
+	if old.Server.ReadinessPath != new.Server.ReadinessPath {
+		fields = append(fields, "server.readiness_check_path")
+	}
+	if old.Server.MetricsPort != new.Server.MetricsPort {
+		fields = append(fields, "server.metrics_port")
+	}
+	if old.Server.MetricsPath != new.Server.MetricsPath {
+		fields = append(fields, "server.metrics_path")
+	}
+	if old.Server.RequestIDHeader != new.Server.RequestIDHeader {
+		fields = append(fields, "server.request_id_header")
+	}
+	// the certificates are reloaded, while switching between TLS and plaintext, and the port of the ACME HTTP-01 challenge server require the listener to restart
+	for _, name := range ListenerNames {
+		ot, nt := old.Server.ListenerTLS(name), new.Server.ListenerTLS(name)
+		if ot.Enabled != nt.Enabled || acmeHTTPPort(ot) != acmeHTTPPort(nt) ||
+			old.Server.Listener(name).H2C != new.Server.Listener(name).H2C {
+			fields = append(fields, "server.listeners."+name)
+		}
+	}
+	if !reflect.DeepEqual(old.Tracing, new.Tracing) {
+		fields = append(fields, "tracing")
+	}
+	// the log level is applied without restart
+	ol, nl := old.Logging, new.Logging
+	ol.Level, nl.Level = "", ""
+	if !reflect.DeepEqual(ol, nl) {
+		fields = append(fields, "logging")
+	}
+	return fields
+}
+
+// acmeHTTPPort returns the port of the ACME HTTP-01 challenge server of t, or 0 when it is not served.
+func acmeHTTPPort(t TLS) int {
+	if !t.Enabled || t.Source != CertSourceACME {
+		return 0
+	}
+	return t.ACME.HTTPPort
+}
+
+// checksum returns the SHA-256 checksum of the file contents, or nil when any of the files cannot be read.
+// The directory is checksummed by the names and the contents of its files.
+func checksum(paths ...string) []byte {
+	h := sha256.New()
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil
+		}
+		if !fi.IsDir() {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			h.Write(b)
+			continue
+		}
+		if err = checksumDir(h, path); err != nil {
+			return nil
+		}
+	}
+	return h.Sum(nil)
+}
+
+// checksumDir writes the names and the contents of the files in dir to h.
+// The hidden entries, e.g. the ..data symbolic link and the timestamped directories of the K8s Secret volume, and the sub directories are skipped,
+// since the files in dir are the symbolic links to them.
+func checksumDir(h hash.Hash, dir string) error {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, fi.Name())
+		if fi, err = os.Stat(path); err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		h.Write([]byte(fi.Name()))
+		h.Write([]byte{0})
+		h.Write(b)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	type test struct {
+		name      string
+		content   string
+		watchDir  bool
+		modify    func(path string) error
+		wantEvent bool
+	}
+	tests := []test{
+		{
+			name:    "Watch notifies when the file content is changed",
+			content: "version: v1.0.0\n",
+			modify: func(path string) error {
+				return ioutil.WriteFile(path, []byte("version: v1.0.1\n"), 0600)
+			},
+			wantEvent: true,
+		},
+		{
+			name:    "Watch does not notify when the same content is written",
+			content: "version: v1.0.0\n",
+			modify: func(path string) error {
+				return ioutil.WriteFile(path, []byte("version: v1.0.0\n"), 0600)
+			},
+			wantEvent: false,
+		},
+		{
+			name:    "Watch does not notify when the file is removed",
+			content: "version: v1.0.0\n",
+			modify: func(path string) error {
+				return os.Remove(path)
+			},
+			wantEvent: false,
+		},
+		{
+			name:     "Watch notifies when the file in the directory is changed",
+			content:  "version: v1.0.0\n",
+			watchDir: true,
+			modify: func(path string) error {
+				return ioutil.WriteFile(path, []byte("version: v1.0.1\n"), 0600)
+			},
+			wantEvent: true,
+		},
+		{
+			name:     "Watch notifies when the file is added to the directory",
+			content:  "version: v1.0.0\n",
+			watchDir: true,
+			modify: func(path string) error {
+				return ioutil.WriteFile(filepath.Join(filepath.Dir(path), "ca.crt"), []byte("ca"), 0600)
+			},
+			wantEvent: true,
+		},
+		{
+			name:     "Watch does not notify when the hidden file is added to the directory",
+			content:  "version: v1.0.0\n",
+			watchDir: true,
+			modify: func(path string) error {
+				return ioutil.WriteFile(filepath.Join(filepath.Dir(path), "..data"), []byte("data"), 0600)
+			},
+			wantEvent: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "config")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "config.yaml")
+			if err = ioutil.WriteFile(path, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			watched := path
+			if tt.watchDir {
+				watched = dir
+			}
+			ch := Watch(ctx, time.Millisecond*10, watched)
+			time.Sleep(time.Millisecond * 30)
+
+			if err = tt.modify(path); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case <-ch:
+				if !tt.wantEvent {
+					t.Error("Watch() notified, but no change is expected")
+				}
+			case <-time.After(time.Millisecond * 200):
+				if tt.wantEvent {
+					t.Error("Watch() did not notify the change")
+				}
+			}
+		})
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	base := Config{
+		Version: "v1.0.0",
+		Server: Server{
+			GrpcPort:         8083,
+			GrpcWebPort:      8082,
+			RestPort:         8081,
+			HealthzPort:      8080,
+			HealthzPath:      "/healthz",
+			Timeout:          "3s",
+			ShutdownDuration: "5s",
+			ProbeWaitTime:    "3s",
+		},
+	}
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{
+			name:   "Same configuration requires no restart",
+			modify: func(*Config) {},
+			want:   []string{},
+		},
+		{
+			name: "Durations are reloadable",
+			modify: func(cfg *Config) {
+				cfg.Server.Timeout = "10s"
+				cfg.Server.ShutdownDuration = "30s"
+				cfg.Server.ProbeWaitTime = "10s"
+			},
+			want: []string{},
+		},
+		{
+			name: "Log level is reloadable but the other logging fields require restart",
+			modify: func(cfg *Config) {
+				cfg.Logging.Level = "debug"
+				cfg.Logging.Format = "json"
+			},
+			want: []string{"logging"},
+		},
+		{
+			name: "Ports and health check path require restart",
+			modify: func(cfg *Config) {
+				cfg.Server.RestPort = 9081
+				cfg.Server.HealthzPort = 9080
+				cfg.Server.HealthzPath = "/health"
+			},
+			want: []string{
+				"server.http_port",
+				"server.health_check_port",
+				"server.health_check_path",
+			},
+		},
+		{
+			name: "Certificates are reloadable but switching to plaintext requires restart",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.CertKey = "NEW_CERT"
+				cfg.Server.Listeners.GRPCWeb.TLS = &TLS{}
+				cfg.Server.Listeners.GRPCWeb.H2C = true
+			},
+			want: []string{"server.listeners.grpc_web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.modify(&cfg)
+			if got := RestartRequired(base, cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestartRequired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// load decodes the configuration file into a raw map, the format is detected from the file extension.
+// It also returns the position of every key in the file, keyed by the field path.
+func load(path string) (map[string]interface{}, map[string]position, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		raw map[string]interface{}
+		pos map[string]position
+	)
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		var n yaml.Node
+		err = yaml.Unmarshal(b, &n)
+		if err == nil {
+			var doc interface{}
+			err = n.Decode(&doc)
+			raw, _ = normalize(doc).(map[string]interface{})
+			pos = yamlPositions(&n)
+		}
+	case ".json":
+		err = json.Unmarshal(b, &raw)
+		pos = jsonPositions(b)
+	case ".toml":
+		_, err = toml.Decode(string(b), &raw)
+		pos = tomlPositions(b)
+	default:
+		return nil, nil, errors.Errorf("unsupported configuration format %q", ext)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if raw == nil {
+		raw = make(map[string]interface{})
+	}
+	return raw, pos, nil
+}
+
+// merge deep merges src into dst.
+// The nested maps are merged recursively, and any other value in src, including a list, replaces the value in dst.
+func merge(dst, src map[string]interface{}) {
+	for k, sv := range src {
+		sm, ok := sv.(map[string]interface{})
+		if !ok {
+			dst[k] = sv
+			continue
+		}
+		dm, ok := dst[k].(map[string]interface{})
+		if !ok {
+			dm = make(map[string]interface{}, len(sm))
+			dst[k] = dm
+		}
+		merge(dm, sm)
+	}
+}
+
+// normalize converts the yaml decoded map[interface{}]interface{} into map[string]interface{} recursively.
+func normalize(val interface{}) interface{} {
+	switch v := val.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	}
+	return val
+}
@@ -0,0 +1,228 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			os.RemoveAll(dir)
+			t.Fatal(err)
+		}
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func Test_load(t *testing.T) {
+	dir, cleanup := writeFiles(t, map[string]string{
+		"config.yaml": "server:\n  http_port: 443\n  tls:\n    enabled: true\n",
+		"config.json": `{"server": {"http_port": 443, "tls": {"enabled": true}}}`,
+		"config.toml": "[server]\nhttp_port = 443\n[server.tls]\nenabled = true\n",
+		"config.ini":  "[server]\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{
+			name: "Load yaml",
+			path: "config.yaml",
+		},
+		{
+			name: "Load json",
+			path: "config.json",
+		},
+		{
+			name: "Load toml",
+			path: "config.toml",
+		},
+		{
+			name:    "Unsupported format returns error",
+			path:    "config.ini",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := load(filepath.Join(dir, tt.path))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("load() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+
+			srv, ok := got["server"].(map[string]interface{})
+			if !ok {
+				t.Errorf("load() server = %T, want map[string]interface{}", got["server"])
+				return
+			}
+			if port := reflect.ValueOf(srv["http_port"]); port.Convert(reflect.TypeOf(0)).Int() != 443 {
+				t.Errorf("load() http_port = %v, want %v", srv["http_port"], 443)
+			}
+			tls, ok := srv["tls"].(map[string]interface{})
+			if !ok || tls["enabled"] != true {
+				t.Errorf("load() tls = %v, want enabled", srv["tls"])
+			}
+		})
+	}
+}
+
+func Test_merge(t *testing.T) {
+	dst := map[string]interface{}{
+		"version": "v1.1.0",
+		"server": map[string]interface{}{
+			"http_port": 443,
+			"timeout":   "3s",
+			"tls": map[string]interface{}{
+				"enabled":  true,
+				"cert_key": "cert",
+			},
+		},
+	}
+	src := map[string]interface{}{
+		"server": map[string]interface{}{
+			"timeout": "10s",
+			"tls": map[string]interface{}{
+				"enabled": false,
+			},
+		},
+	}
+	want := map[string]interface{}{
+		"version": "v1.1.0",
+		"server": map[string]interface{}{
+			"http_port": 443,
+			"timeout":   "10s",
+			"tls": map[string]interface{}{
+				"enabled":  false,
+				"cert_key": "cert",
+			},
+		},
+	}
+
+	merge(dst, src)
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("merge() = %v, want %v", dst, want)
+	}
+}
+
+func TestNew_layered(t *testing.T) {
+	dir, cleanup := writeFiles(t, map[string]string{
+		"base.yaml":     "version: v1.0.0\nserver:\n  port: 443\n  health_check_port: 8080\n  timeout: 3s\n",
+		"overlay.json":  `{"server": {"health_check_path": "/healthz", "timeout": "5s"}}`,
+		"override.toml": "[server]\ntimeout = \"10s\"\n",
+		"newer.yaml":    "version: v99.0.0\n",
+	})
+	defer cleanup()
+
+	cfg, err := New(
+		filepath.Join(dir, "base.yaml"),
+		filepath.Join(dir, "overlay.json"),
+		filepath.Join(dir, "override.toml"),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	want := Server{
+		RestPort:    443,
+		HealthzPort: 8080,
+		HealthzPath: "/healthz",
+		Timeout:     "10s",
+	}
+	if cfg.Version != currentVersion || !reflect.DeepEqual(cfg.Server, want) {
+		t.Errorf("New() = %+v, want %+v", cfg.Server, want)
+	}
+
+	_, err = New(filepath.Join(dir, "base.yaml"), filepath.Join(dir, "newer.yaml"))
+	if err == nil {
+		t.Error("New() with newer version overlay error = nil, want error")
+	}
+}
+
+func TestNew_strict(t *testing.T) {
+	dir, cleanup := writeFiles(t, map[string]string{
+		"base.yaml":    "version: v1.1.0\nserver:\n  port: 443\n  http_port: 443\n  tls:\n    enabled: true\n    cert: cert\n",
+		"overlay.json": "{\n  \"server\": {\n    \"timeout\": \"5s\",\n    \"timeuot\": \"5s\"\n  },\n  \"debug\": true\n}",
+		"overlay.toml": "[server]\ntimeout = \"10s\"\n  health_port = 8080\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{
+			name:  "Missing file is not created",
+			paths: []string{"missing.yaml"},
+		},
+		{
+			name:  "Unknown yaml keys are reported with position",
+			paths: []string{"base.yaml"},
+			want: []string{
+				"base.yaml:3:3: server.port: unknown field",
+				"base.yaml:7:5: server.tls.cert: unknown field",
+			},
+		},
+		{
+			name:  "Unknown keys of every file are reported",
+			paths: []string{"base.yaml", "overlay.json", "overlay.toml"},
+			want: []string{
+				"base.yaml:3:3: server.port: unknown field",
+				"base.yaml:7:5: server.tls.cert: unknown field",
+				"overlay.json:6:3: debug: unknown field",
+				"overlay.json:4:5: server.timeuot: unknown field",
+				"overlay.toml:3:3: server.health_port: unknown field",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths := make([]string, 0, len(tt.paths))
+			for _, p := range tt.paths {
+				paths = append(paths, filepath.Join(dir, p))
+			}
+
+			_, err := New(paths...)
+			if err == nil {
+				t.Error("New() error = nil, want error")
+				return
+			}
+			if _, serr := os.Stat(filepath.Join(dir, "missing.yaml")); !os.IsNotExist(serr) {
+				t.Error("New() created the missing file")
+			}
+			if len(tt.want) == 0 {
+				return
+			}
+
+			verr, ok := err.(ValidationError)
+			if !ok {
+				t.Errorf("New() error = %v, want ValidationError", err)
+				return
+			}
+			got := make([]string, 0, len(verr))
+			for _, fe := range verr {
+				got = append(got, strings.TrimPrefix(fe.Error(), dir+string(filepath.Separator)))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("New() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,554 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldError represents a validation error of a configuration field.
+type FieldError struct {
+	// Field represents the yaml field path, e.g. "server.shutdown_duration".
+	Field string
+
+	// Message represents the reason why the field is invalid.
+	Message string
+
+	// File represents the configuration file which defines the field, it is empty when the field is validated after merging.
+	File string
+
+	// Line and Column represent the position of the field key in the File.
+	Line   int
+	Column int
+}
+
+// ValidationError represents all of the validation errors found in a configuration.
+type ValidationError []*FieldError
+
+const (
+	// minPort represents the minimum listen port number
+	minPort = 1
+
+	// maxPort represents the maximum listen port number
+	maxPort = 65535
+)
+
+// Error returns the field path and the reason, prefixed by the file position when it is known.
+func (e *FieldError) Error() string {
+	switch {
+	case e.File != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s: %s", e.File, e.Line, e.Column, e.Field, e.Message)
+	case e.File != "":
+		return fmt.Sprintf("%s: %s: %s", e.File, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Error returns all of the field errors joined by "; ".
+func (e ValidationError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(msgs, "; "))
+}
+
+// Validate returns ValidationError which reports every invalid field in the configuration, or nil when the configuration is valid.
+func (c *Config) Validate() error {
+	return c.validate("").err()
+}
+
+// Validate returns ValidationError which reports every invalid field in the server configuration, or nil when the configuration is valid.
+func (s *Server) Validate() error {
+	return s.validate("").err()
+}
+
+// Validate returns ValidationError which reports every invalid field in the TLS configuration, or nil when the configuration is valid.
+func (t *TLS) Validate() error {
+	return t.validate("").err()
+}
+
+func (c *Config) validate(prefix string) ValidationError {
+	var errs ValidationError
+	switch c.Version {
+	case "":
+		errs = errs.add(prefix, "version", "required")
+	case currentVersion:
+	default:
+		errs = errs.add(prefix, "version", fmt.Sprintf("unsupported version %q, must be %q", c.Version, currentVersion))
+	}
+	errs = append(errs, c.Server.validate(join(prefix, "server"))...)
+	errs = append(errs, c.Tracing.validate(join(prefix, "tracing"))...)
+	errs = append(errs, c.Logging.validate(join(prefix, "logging"))...)
+
+	// the admin endpoint of the log level is served by the health check server
+	if lp := c.Logging.LevelPath; strings.HasPrefix(lp, "/") &&
+		(lp == c.Server.HealthzPath || lp == c.Server.ReadinessPath || (c.Server.MetricsPort == 0 && lp == c.Server.MetricsPath)) {
+		errs = errs.add(prefix, "logging.level_path", fmt.Sprintf("path %q collides with the health check paths", lp))
+	}
+	return errs
+}
+
+func (s *Server) validate(prefix string) ValidationError {
+	var errs ValidationError
+
+	switch s.Mode {
+	case "", ModeProduction, ModeDevelopment:
+	default:
+		errs = errs.add(prefix, "mode", fmt.Sprintf("unsupported mode %q, must be %q or %q", s.Mode, ModeProduction, ModeDevelopment))
+	}
+
+	ports := map[string]int{
+		"grpc_port":         s.GrpcPort,
+		"grpc_web_port":     s.GrpcWebPort,
+		"http_port":         s.RestPort,
+		"health_check_port": s.HealthzPort,
+	}
+	// the metrics are served by the health check server when metrics_port is not defined
+	if s.MetricsPort != 0 {
+		ports["metrics_port"] = s.MetricsPort
+	}
+	// the listeners of the same ACME configuration share the HTTP-01 challenge server
+	var acmes []ACME
+	for _, name := range ACMEListenerNames {
+		t := s.ListenerTLS(name)
+		if !t.Enabled || t.Source != CertSourceACME || t.ACME.HTTPPort < minPort || t.ACME.HTTPPort > maxPort || containsACME(acmes, t.ACME) {
+			continue
+		}
+		acmes = append(acmes, t.ACME)
+		field := "tls.acme.http_port"
+		if s.Listener(name).TLS != nil {
+			field = "listeners." + name + ".tls.acme.http_port"
+		}
+		ports[field] = t.ACME.HTTPPort
+	}
+	names := make([]string, 0, len(ports))
+	for name := range ports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := make(map[int]string, len(ports))
+	for _, name := range names {
+		port := ports[name]
+		if port < minPort || port > maxPort {
+			errs = errs.add(prefix, name, fmt.Sprintf("invalid port %d, must be between %d and %d", port, minPort, maxPort))
+			continue
+		}
+		if other, ok := used[port]; ok {
+			errs = errs.add(prefix, name, fmt.Sprintf("port %d collides with %s", port, join(prefix, other)))
+			continue
+		}
+		used[port] = name
+	}
+
+	switch {
+	case s.HealthzPath == "":
+		errs = errs.add(prefix, "health_check_path", "required")
+	case !strings.HasPrefix(s.HealthzPath, "/"):
+		errs = errs.add(prefix, "health_check_path", fmt.Sprintf("invalid path %q, must start with \"/\"", s.HealthzPath))
+	}
+
+	if s.RequestIDHeader != "" && !isToken(s.RequestIDHeader) {
+		errs = errs.add(prefix, "request_id_header", fmt.Sprintf("invalid header name %q", s.RequestIDHeader))
+	}
+
+	switch {
+	case s.ReadinessPath == "":
+	case !strings.HasPrefix(s.ReadinessPath, "/"):
+		errs = errs.add(prefix, "readiness_check_path", fmt.Sprintf("invalid path %q, must start with \"/\"", s.ReadinessPath))
+	case s.ReadinessPath == s.HealthzPath:
+		errs = errs.add(prefix, "readiness_check_path", fmt.Sprintf("path %q collides with %s", s.ReadinessPath, join(prefix, "health_check_path")))
+	}
+
+	switch {
+	case s.MetricsPath == "":
+	case !strings.HasPrefix(s.MetricsPath, "/"):
+		errs = errs.add(prefix, "metrics_path", fmt.Sprintf("invalid path %q, must start with \"/\"", s.MetricsPath))
+	case s.MetricsPort == 0 && (s.MetricsPath == s.HealthzPath || s.MetricsPath == s.ReadinessPath):
+		errs = errs.add(prefix, "metrics_path", fmt.Sprintf("path %q collides with the health check paths", s.MetricsPath))
+	}
+
+	errs = errs.addTimeout(prefix, "timeout", s.Timeout)
+	errs = errs.addDuration(prefix, "shutdown_duration", s.ShutdownDuration)
+	errs = errs.addDuration(prefix, "probe_wait_time", s.ProbeWaitTime)
+
+	errs = append(errs, s.TLS.validate(join(prefix, "tls"))...)
+	for _, name := range ListenerNames {
+		errs = append(errs, s.validateListener(join(prefix, "listeners."+name), name)...)
+	}
+
+	for _, name := range ListenerNames {
+		if t := s.ListenerTLS(name); !t.Enabled || t.Source != CertSourceACME || indexOf(ACMEListenerNames, name) >= 0 {
+			continue
+		}
+		field := "tls.source"
+		if s.Listener(name).TLS != nil {
+			field = "listeners." + name + ".tls.source"
+		}
+		errs = errs.add(prefix, field, fmt.Sprintf("certificate source %q is not supported by the %s listener, override its TLS in listeners.%s.tls", CertSourceACME, name, name))
+	}
+
+	// the generated certificates are not trusted by any client, so that they must not be served in production
+	if s.Mode != ModeDevelopment {
+		if s.TLS.Enabled && s.TLS.Source == CertSourceDev {
+			errs = errs.add(prefix, "tls.source", fmt.Sprintf("certificate source %q requires the %q mode", CertSourceDev, ModeDevelopment))
+		}
+		for _, name := range ListenerNames {
+			if t := s.Listener(name).TLS; t != nil && t.Enabled && t.Source == CertSourceDev {
+				errs = errs.add(prefix, "listeners."+name+".tls.source", fmt.Sprintf("certificate source %q requires the %q mode", CertSourceDev, ModeDevelopment))
+			}
+		}
+	}
+	return errs
+}
+
+func (s *Server) validateListener(prefix, name string) ValidationError {
+	var errs ValidationError
+	l := s.Listener(name)
+	if l.TLS != nil {
+		errs = append(errs, l.TLS.validate(join(prefix, "tls"))...)
+	}
+	switch {
+	case !l.H2C:
+	case name == ListenerGRPC:
+		errs = errs.add(prefix, "h2c", "not supported, the gRPC listener always serves HTTP/2")
+	case s.ListenerTLS(name).Enabled:
+		errs = errs.add(prefix, "h2c", "requires TLS to be disabled on the listener")
+	}
+	return errs
+}
+
+func (t *TLS) validate(prefix string) ValidationError {
+	var errs ValidationError
+	if !t.Enabled {
+		return errs
+	}
+	errs = append(errs, t.validateSource(prefix)...)
+	errs = errs.addDuration(prefix, "watch_interval", t.WatchInterval)
+
+	min, max := indexOf(TLSVersions, t.MinVersion), indexOf(TLSVersions, t.MaxVersion)
+	if t.MinVersion != "" && min < 0 {
+		errs = errs.add(prefix, "min_version", fmt.Sprintf("unsupported TLS version %q, must be one of %s", t.MinVersion, strings.Join(TLSVersions, ", ")))
+	}
+	if t.MaxVersion != "" && max < 0 {
+		errs = errs.add(prefix, "max_version", fmt.Sprintf("unsupported TLS version %q, must be one of %s", t.MaxVersion, strings.Join(TLSVersions, ", ")))
+	}
+	if min >= 0 && max >= 0 && min > max {
+		errs = errs.add(prefix, "max_version", fmt.Sprintf("TLS version %q is lower than the min_version %q", t.MaxVersion, t.MinVersion))
+	}
+	for _, name := range t.CipherSuites {
+		if msg := validateCipherSuite(name); msg != "" {
+			errs = errs.add(prefix, "cipher_suites", msg)
+		}
+	}
+	for _, name := range t.CurvePreferences {
+		if indexOf(TLSCurves, name) < 0 {
+			errs = errs.add(prefix, "curve_preferences", fmt.Sprintf("unsupported curve %q, must be one of %s", name, strings.Join(TLSCurves, ", ")))
+		}
+	}
+
+	// the CA certificate of the secret source is the optional ca.crt, which is not known until it is loaded
+	secret := t.Source == CertSourceSecret
+	verify := t.ClientAuth == "" && (t.CAKey != "" || secret)
+	switch t.ClientAuth {
+	case "", ClientAuthNone, ClientAuthRequest, ClientAuthRequireAny:
+	case ClientAuthVerifyIfGiven, ClientAuthRequireAndVerify:
+		verify = true
+		if t.CAKey == "" && !secret {
+			errs = errs.add(prefix, "ca_key", fmt.Sprintf("required when the client auth mode is %q", t.ClientAuth))
+		}
+	default:
+		errs = errs.add(prefix, "client_auth", fmt.Sprintf("unsupported client auth mode %q, must be %q, %q, %q, %q or %q", t.ClientAuth,
+			ClientAuthNone, ClientAuthRequest, ClientAuthRequireAny, ClientAuthVerifyIfGiven, ClientAuthRequireAndVerify))
+	}
+	if !verify && len(t.AllowedClientSubjects)+len(t.AllowedClientSANs) > 0 {
+		errs = errs.add(prefix, "client_auth", "the allowed client patterns require the client certificate to be verified, "+
+			fmt.Sprintf("the mode must be %q or %q", ClientAuthVerifyIfGiven, ClientAuthRequireAndVerify))
+	}
+	return errs
+}
+
+// validateSource returns the errors of the fields required by the certificate source.
+func (t *TLS) validateSource(prefix string) ValidationError {
+	var errs ValidationError
+	switch t.Source {
+	case "", CertSourceFile, CertSourceEnv:
+		if t.CertKey == "" {
+			errs = errs.add(prefix, "cert_key", "required when TLS is enabled")
+		}
+		if t.KeyKey == "" {
+			errs = errs.add(prefix, "key_key", "required when TLS is enabled")
+		}
+		for i, c := range t.Certificates {
+			if c.CertKey == "" {
+				errs = errs.add(prefix, fmt.Sprintf("certificates[%d].cert_key", i), "required")
+			}
+			if c.KeyKey == "" {
+				errs = errs.add(prefix, fmt.Sprintf("certificates[%d].key_key", i), "required")
+			}
+		}
+	case CertSourceSecret:
+		if t.SecretDir == "" {
+			errs = errs.add(prefix, "secret_dir", fmt.Sprintf("required when the certificate source is %q", t.Source))
+		}
+		for i, c := range t.Certificates {
+			if c.SecretDir == "" {
+				errs = errs.add(prefix, fmt.Sprintf("certificates[%d].secret_dir", i), "required")
+			}
+		}
+	case CertSourcePKCS12:
+		if t.CertKey == "" {
+			errs = errs.add(prefix, "cert_key", "required when TLS is enabled")
+		}
+		for i, c := range t.Certificates {
+			if c.CertKey == "" {
+				errs = errs.add(prefix, fmt.Sprintf("certificates[%d].cert_key", i), "required")
+			}
+		}
+	case CertSourceDev:
+		errs = errs.addDuration(join(prefix, "dev"), "valid_for", t.Dev.ValidFor)
+		if len(t.Certificates) > 0 {
+			errs = errs.add(prefix, "certificates", fmt.Sprintf("not supported by the certificate source %q, add the hostnames to dev.hosts instead", t.Source))
+		}
+	case CertSourceACME:
+		errs = append(errs, t.ACME.validate(join(prefix, "acme"))...)
+		if len(t.Certificates) > 0 {
+			errs = errs.add(prefix, "certificates", fmt.Sprintf("not supported by the certificate source %q, add the domains to acme.domains instead", t.Source))
+		}
+	default:
+		errs = errs.add(prefix, "source", fmt.Sprintf("unsupported certificate source %q, must be %q, %q, %q, %q, %q or %q", t.Source,
+			CertSourceFile, CertSourceEnv, CertSourceSecret, CertSourcePKCS12, CertSourceDev, CertSourceACME))
+	}
+	return errs
+}
+
+func (a *ACME) validate(prefix string) ValidationError {
+	var errs ValidationError
+	if len(a.Domains) == 0 {
+		errs = errs.add(prefix, "domains", fmt.Sprintf("required when the certificate source is %q", CertSourceACME))
+	}
+	for _, d := range a.Domains {
+		// the ACME server issues the certificates only for the fully qualified domain names
+		if !strings.Contains(strings.Trim(d, "."), ".") || strings.ContainsAny(d, `*+/\: `) {
+			errs = errs.add(prefix, "domains", fmt.Sprintf("invalid domain %q", d))
+		}
+	}
+	if a.DirectoryURL != "" {
+		if u, err := url.Parse(a.DirectoryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = errs.add(prefix, "directory_url", fmt.Sprintf("invalid URL %q, must be a http or https URL", a.DirectoryURL))
+		}
+	}
+	if a.CacheDir == "" {
+		errs = errs.add(prefix, "cache_dir", fmt.Sprintf("required when the certificate source is %q", CertSourceACME))
+	}
+	if a.HTTPPort != 0 && (a.HTTPPort < minPort || a.HTTPPort > maxPort) {
+		errs = errs.add(prefix, "http_port", fmt.Sprintf("invalid port %d, must be between %d and %d", a.HTTPPort, minPort, maxPort))
+	}
+	errs = errs.addDuration(prefix, "renew_before", a.RenewBefore)
+	return errs
+}
+
+// containsACME returns true when acmes contains a.
+func containsACME(acmes []ACME, a ACME) bool {
+	for _, v := range acmes {
+		if reflect.DeepEqual(v, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCipherSuite returns the reason why the cipher suite cannot be configured, or empty string when it is supported.
+func validateCipherSuite(name string) string {
+	for _, c := range tls.CipherSuites() {
+		if c.Name != name {
+			continue
+		}
+		for _, v := range c.SupportedVersions {
+			if v != tls.VersionTLS13 {
+				return ""
+			}
+		}
+		return fmt.Sprintf("TLS 1.3 cipher suite %q is not configurable", name)
+	}
+	for _, c := range tls.InsecureCipherSuites() {
+		if c.Name == name {
+			return fmt.Sprintf("insecure cipher suite %q is not supported", name)
+		}
+	}
+	return fmt.Sprintf("unknown cipher suite %q", name)
+}
+
+// indexOf returns the index of val in vals, or -1 when it is not found.
+func indexOf(vals []string, val string) int {
+	for i, v := range vals {
+		if v == val {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Tracing) validate(prefix string) ValidationError {
+	var errs ValidationError
+	if !t.Enabled {
+		return errs
+	}
+	if t.SamplingRatio < 0 || t.SamplingRatio > 1 {
+		errs = errs.add(prefix, "sampling_ratio", fmt.Sprintf("invalid ratio %v, must be between 0 and 1", t.SamplingRatio))
+	}
+	switch t.Exporter {
+	case "", "stdout":
+	case "file":
+		if t.FilePath == "" {
+			errs = errs.add(prefix, "file_path", "required when the exporter is \"file\"")
+		}
+	case "otlp":
+		switch u, err := url.Parse(t.OTLP.Endpoint); {
+		case t.OTLP.Endpoint == "":
+			errs = errs.add(prefix, "otlp.endpoint", "required when the exporter is \"otlp\"")
+		case err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "":
+			errs = errs.add(prefix, "otlp.endpoint", fmt.Sprintf("invalid URL %q, must be a http or https URL", t.OTLP.Endpoint))
+		}
+		for _, h := range t.OTLP.Headers {
+			if strings.Index(h, "=") <= 0 {
+				// the header value may be a secret, so only the position is reported
+				errs = errs.add(prefix, "otlp.headers", "invalid header, must be \"Key=Value\"")
+				break
+			}
+		}
+		errs = errs.addTimeout(prefix, "otlp.timeout", t.OTLP.Timeout)
+	default:
+		errs = errs.add(prefix, "exporter", fmt.Sprintf("unsupported exporter %q, must be \"stdout\", \"file\" or \"otlp\"", t.Exporter))
+	}
+	return errs
+}
+
+func (l *Logging) validate(prefix string) ValidationError {
+	var errs ValidationError
+	switch strings.ToLower(l.Level) {
+	case "", "debug", "info", "warn", "error":
+	default:
+		errs = errs.add(prefix, "level", fmt.Sprintf("unsupported level %q, must be \"debug\", \"info\", \"warn\" or \"error\"", l.Level))
+	}
+	switch l.Format {
+	case "", "text", "json":
+	default:
+		errs = errs.add(prefix, "format", fmt.Sprintf("unsupported format %q, must be \"text\" or \"json\"", l.Format))
+	}
+	switch l.Output {
+	case "", "stdout":
+	case "file":
+		if l.File.Path == "" {
+			errs = errs.add(prefix, "file.path", "required when the output is \"file\"")
+		}
+		if l.File.MaxSizeMB < 0 {
+			errs = errs.add(prefix, "file.max_size_mb", fmt.Sprintf("invalid size %d, must not be negative", l.File.MaxSizeMB))
+		}
+		if l.File.MaxBackups < 0 {
+			errs = errs.add(prefix, "file.max_backups", fmt.Sprintf("invalid number %d, must not be negative", l.File.MaxBackups))
+		}
+	default:
+		errs = errs.add(prefix, "output", fmt.Sprintf("unsupported output %q, must be \"stdout\" or \"file\"", l.Output))
+	}
+	if l.LevelPath != "" && !strings.HasPrefix(l.LevelPath, "/") {
+		errs = errs.add(prefix, "level_path", fmt.Sprintf("invalid path %q, must start with \"/\"", l.LevelPath))
+	}
+	return append(errs, l.Access.validate(join(prefix, "access"))...)
+}
+
+func (a *AccessLog) validate(prefix string) ValidationError {
+	var errs ValidationError
+	if !a.Enabled {
+		return errs
+	}
+	switch a.Format {
+	case "", "json", "logfmt":
+	default:
+		errs = errs.add(prefix, "format", fmt.Sprintf("unsupported format %q, must be \"json\" or \"logfmt\"", a.Format))
+	}
+	if a.SamplingRatio < 0 || a.SamplingRatio > 1 {
+		errs = errs.add(prefix, "sampling_ratio", fmt.Sprintf("invalid ratio %v, must be between 0 and 1", a.SamplingRatio))
+	}
+	for _, p := range a.ExcludePaths {
+		if !strings.HasPrefix(p, "/") {
+			errs = errs.add(prefix, "exclude_paths", fmt.Sprintf("invalid path %q, must start with \"/\"", p))
+		}
+	}
+	return errs
+}
+
+// add appends the FieldError of the field under prefix.
+func (e ValidationError) add(prefix, field, msg string) ValidationError {
+	return append(e, &FieldError{
+		Field:   join(prefix, field),
+		Message: msg,
+	})
+}
+
+// addDuration appends the FieldError when the val is not empty and is not a valid non-negative duration.
+// The empty value is allowed, and the default value is used instead.
+func (e ValidationError) addDuration(prefix, field, val string) ValidationError {
+	if val == "" {
+		return e
+	}
+	dur, err := time.ParseDuration(val)
+	if err != nil {
+		return e.add(prefix, field, fmt.Sprintf("invalid duration %q", val))
+	}
+	if dur < 0 {
+		return e.add(prefix, field, fmt.Sprintf("invalid duration %q, must not be negative", val))
+	}
+	return e
+}
+
+// addTimeout appends the FieldError when the val is not empty and is not a valid positive duration, since the zero timeout expires every request.
+// The empty value is allowed, and the default value is used instead.
+func (e ValidationError) addTimeout(prefix, field, val string) ValidationError {
+	if val == "" {
+		return e
+	}
+	dur, err := time.ParseDuration(val)
+	if err != nil {
+		return e.add(prefix, field, fmt.Sprintf("invalid duration %q", val))
+	}
+	if dur <= 0 {
+		return e.add(prefix, field, fmt.Sprintf("invalid duration %q, must be positive", val))
+	}
+	return e
+}
+
+// err returns nil when there is no FieldError, so that the nil ValidationError is not returned as a non-nil error interface.
+func (e ValidationError) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// join returns the yaml field path of the field under prefix.
+func join(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	return prefix + "." + field
+}
+
+// isToken returns true when s is a valid HTTP header name, which consists of the RFC 7230 token characters.
+func isToken(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return len(s) > 0
+}
@@ -0,0 +1,417 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func validConfig() Config {
+	return Config{
+		Version: currentVersion,
+		Server: Server{
+			GrpcPort:         8083,
+			GrpcWebPort:      8082,
+			RestPort:         8081,
+			HealthzPort:      8080,
+			HealthzPath:      "/healthz",
+			Timeout:          "3s",
+			ShutdownDuration: "5s",
+			ProbeWaitTime:    "3s",
+			TLS: TLS{
+				Enabled: true,
+				CertKey: "cert",
+				KeyKey:  "key",
+			},
+		},
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string
+	}{
+		{
+			name:   "Valid configuration",
+			modify: func(*Config) {},
+		},
+		{
+			name: "Empty durations are allowed",
+			modify: func(cfg *Config) {
+				cfg.Server.Timeout = ""
+				cfg.Server.ShutdownDuration = ""
+				cfg.Server.ProbeWaitTime = ""
+			},
+		},
+		{
+			name: "Zero timeout is rejected but the other zero durations are allowed",
+			modify: func(cfg *Config) {
+				cfg.Server.Timeout = "0s"
+				cfg.Server.ShutdownDuration = "0s"
+				cfg.Server.ProbeWaitTime = "0s"
+			},
+			want: []string{
+				`server.timeout: invalid duration "0s", must be positive`,
+			},
+		},
+		{
+			name: "All errors are reported at once",
+			modify: func(cfg *Config) {
+				cfg.Version = ""
+				cfg.Server.Mode = "debug"
+				cfg.Server.GrpcPort = 0
+				cfg.Server.HealthzPath = ""
+				cfg.Server.Timeout = "-1s"
+				cfg.Server.ShutdownDuration = "30sec"
+				cfg.Server.TLS.CertKey = ""
+			},
+			want: []string{
+				`version: required`,
+				`server.mode: unsupported mode "debug", must be "production" or "development"`,
+				`server.grpc_port: invalid port 0, must be between 1 and 65535`,
+				`server.health_check_path: required`,
+				`server.timeout: invalid duration "-1s", must be positive`,
+				`server.shutdown_duration: invalid duration "30sec"`,
+				`server.tls.cert_key: required when TLS is enabled`,
+			},
+		},
+		{
+			name: "Invalid request ID header is detected",
+			modify: func(cfg *Config) {
+				cfg.Server.RequestIDHeader = "X Request ID"
+			},
+			want: []string{
+				`server.request_id_header: invalid header name "X Request ID"`,
+			},
+		},
+		{
+			name: "Port collisions are detected",
+			modify: func(cfg *Config) {
+				cfg.Server.GrpcWebPort = 8083
+				cfg.Server.RestPort = 8080
+			},
+			want: []string{
+				`server.grpc_web_port: port 8083 collides with server.grpc_port`,
+				`server.http_port: port 8080 collides with server.health_check_port`,
+			},
+		},
+		{
+			name: "Health check path must be absolute",
+			modify: func(cfg *Config) {
+				cfg.Server.HealthzPath = "healthz"
+			},
+			want: []string{
+				`server.health_check_path: invalid path "healthz", must start with "/"`,
+			},
+		},
+		{
+			name: "Readiness path must not collide with health check path",
+			modify: func(cfg *Config) {
+				cfg.Server.ReadinessPath = "/healthz"
+			},
+			want: []string{
+				`server.readiness_check_path: path "/healthz" collides with server.health_check_path`,
+			},
+		},
+		{
+			name: "Metrics port is checked only when it is defined",
+			modify: func(cfg *Config) {
+				cfg.Server.MetricsPort = 8080
+				cfg.Server.MetricsPath = "/healthz"
+			},
+			want: []string{
+				`server.metrics_port: port 8080 collides with server.health_check_port`,
+			},
+		},
+		{
+			name: "Metrics path must not collide with health check path on the same port",
+			modify: func(cfg *Config) {
+				cfg.Server.MetricsPath = "/healthz"
+			},
+			want: []string{
+				`server.metrics_path: path "/healthz" collides with the health check paths`,
+			},
+		},
+		{
+			name: "TLS keys are not required when TLS is disabled",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS = TLS{}
+			},
+		},
+		{
+			name: "Tracing is not checked when it is disabled",
+			modify: func(cfg *Config) {
+				cfg.Tracing = Tracing{
+					Exporter: "zipkin",
+				}
+			},
+		},
+		{
+			name: "Tracing file exporter requires file path",
+			modify: func(cfg *Config) {
+				cfg.Tracing = Tracing{
+					Enabled:       true,
+					SamplingRatio: 1.5,
+					Exporter:      "file",
+				}
+			},
+			want: []string{
+				`tracing.sampling_ratio: invalid ratio 1.5, must be between 0 and 1`,
+				`tracing.file_path: required when the exporter is "file"`,
+			},
+		},
+		{
+			name: "Tracing OTLP exporter requires valid endpoint and headers",
+			modify: func(cfg *Config) {
+				cfg.Tracing = Tracing{
+					Enabled:  true,
+					Exporter: "otlp",
+					OTLP: OTLP{
+						Endpoint: "localhost:4318",
+						Headers:  []string{"Authorization=Bearer token", "secret"},
+						Timeout:  "10",
+					},
+				}
+			},
+			want: []string{
+				`tracing.otlp.endpoint: invalid URL "localhost:4318", must be a http or https URL`,
+				`tracing.otlp.headers: invalid header, must be "Key=Value"`,
+				`tracing.otlp.timeout: invalid duration "10"`,
+			},
+		},
+		{
+			name: "Tracing exporter must be supported",
+			modify: func(cfg *Config) {
+				cfg.Tracing = Tracing{
+					Enabled:  true,
+					Exporter: "zipkin",
+				}
+			},
+			want: []string{
+				`tracing.exporter: unsupported exporter "zipkin", must be "stdout", "file" or "otlp"`,
+			},
+		},
+		{
+			name: "Client auth mode and patterns are validated",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.ClientAuth = ClientAuthRequireAndVerify
+				cfg.Server.TLS.AllowedClientSANs = []string{"*.example.com"}
+			},
+			want: []string{
+				`server.tls.ca_key: required when the client auth mode is "require-and-verify"`,
+			},
+		},
+		{
+			name: "Allowed client patterns require the verification",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.ClientAuth = ClientAuthRequest
+				cfg.Server.TLS.AllowedClientSubjects = []string{"CN=client"}
+			},
+			want: []string{
+				`server.tls.client_auth: the allowed client patterns require the client certificate to be verified, the mode must be "verify-if-given" or "require-and-verify"`,
+			},
+		},
+		{
+			name: "Certificate watch interval is validated",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.WatchInterval = "10 seconds"
+			},
+			want: []string{
+				`server.tls.watch_interval: invalid duration "10 seconds"`,
+			},
+		},
+		{
+			name: "Listener overrides are validated",
+			modify: func(cfg *Config) {
+				cfg.Server.Listeners.REST.H2C = true
+				cfg.Server.Listeners.GRPC = Listener{
+					TLS: &TLS{Enabled: true},
+					H2C: true,
+				}
+			},
+			want: []string{
+				`server.listeners.rest.h2c: requires TLS to be disabled on the listener`,
+				`server.listeners.grpc.tls.cert_key: required when TLS is enabled`,
+				`server.listeners.grpc.tls.key_key: required when TLS is enabled`,
+				`server.listeners.grpc.h2c: not supported, the gRPC listener always serves HTTP/2`,
+			},
+		},
+		{
+			name: "TLS protocols and certificates are validated",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.Certificates = []Certificate{{CertKey: "SNI_CERT"}}
+				cfg.Server.TLS.MinVersion = "1.3"
+				cfg.Server.TLS.MaxVersion = "1.2"
+				cfg.Server.TLS.CipherSuites = []string{
+					"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+					"TLS_AES_128_GCM_SHA256",
+					"TLS_RSA_WITH_RC4_128_SHA",
+					"TLS_UNKNOWN",
+				}
+				cfg.Server.TLS.CurvePreferences = []string{"X25519", "P224"}
+			},
+			want: []string{
+				`server.tls.certificates[0].key_key: required`,
+				`server.tls.max_version: TLS version "1.2" is lower than the min_version "1.3"`,
+				`server.tls.cipher_suites: TLS 1.3 cipher suite "TLS_AES_128_GCM_SHA256" is not configurable`,
+				`server.tls.cipher_suites: insecure cipher suite "TLS_RSA_WITH_RC4_128_SHA" is not supported`,
+				`server.tls.cipher_suites: unknown cipher suite "TLS_UNKNOWN"`,
+				`server.tls.curve_preferences: unsupported curve "P224", must be one of X25519, P256, P384, P521`,
+			},
+		},
+		{
+			name: "Health check listener TLS is validated only when defined",
+			modify: func(cfg *Config) {
+				cfg.Server.Listeners.HealthCheck = Listener{
+					TLS: &TLS{Enabled: true, CertKey: "HEALTH_CERT", MinVersion: "1.4"},
+					H2C: true,
+				}
+			},
+			want: []string{
+				`server.listeners.health_check.tls.key_key: required when TLS is enabled`,
+				`server.listeners.health_check.tls.min_version: unsupported TLS version "1.4", must be one of 1.0, 1.1, 1.2, 1.3`,
+				`server.listeners.health_check.h2c: requires TLS to be disabled on the listener`,
+			},
+		},
+		{
+			name: "Certificate source fields are validated",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.Source = CertSourceSecret
+				cfg.Server.TLS.ClientAuth = ClientAuthRequireAndVerify
+				cfg.Server.TLS.Certificates = []Certificate{{CertKey: "SNI_CERT"}}
+				cfg.Server.Listeners.REST.TLS = &TLS{Enabled: true, Source: "vault"}
+			},
+			want: []string{
+				`server.tls.secret_dir: required when the certificate source is "secret"`,
+				`server.tls.certificates[0].secret_dir: required`,
+				`server.listeners.rest.tls.source: unsupported certificate source "vault", must be "file", "env", "secret", "pkcs12", "dev" or "acme"`,
+			},
+		},
+		{
+			name: "Dev certificate source is validated and allowed only in the development mode",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.Source = CertSourceDev
+				cfg.Server.TLS.Dev.ValidFor = "1year"
+				cfg.Server.TLS.Certificates = []Certificate{{CertKey: "SNI_CERT", KeyKey: "SNI_KEY"}}
+				cfg.Server.Listeners.REST.TLS = &TLS{Enabled: true, Source: CertSourceDev}
+			},
+			want: []string{
+				`server.tls.dev.valid_for: invalid duration "1year"`,
+				`server.tls.certificates: not supported by the certificate source "dev", add the hostnames to dev.hosts instead`,
+				`server.tls.source: certificate source "dev" requires the "development" mode`,
+				`server.listeners.rest.tls.source: certificate source "dev" requires the "development" mode`,
+			},
+		},
+		{
+			name: "ACME certificate source is validated and supported only by the REST and gRPC-Web listeners",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.Source = CertSourceACME
+				cfg.Server.TLS.ACME = ACME{
+					Domains:      []string{"example.com", "localhost", "*.example.com"},
+					DirectoryURL: "acme.example.com/directory",
+					HTTPPort:     cfg.Server.RestPort,
+					RenewBefore:  "30days",
+				}
+				cfg.Server.Listeners.GRPC.TLS = &TLS{Enabled: true, CertKey: "GRPC_CERT", KeyKey: "GRPC_KEY"}
+				cfg.Server.Listeners.HealthCheck.TLS = &TLS{Enabled: true, Source: CertSourceACME, ACME: ACME{Domains: []string{"example.com"}, CacheDir: "/var/cache/acme"}}
+			},
+			want: []string{
+				`server.tls.acme.http_port: port 8081 collides with server.http_port`,
+				`server.tls.acme.domains: invalid domain "localhost"`,
+				`server.tls.acme.domains: invalid domain "*.example.com"`,
+				`server.tls.acme.directory_url: invalid URL "acme.example.com/directory", must be a http or https URL`,
+				`server.tls.acme.cache_dir: required when the certificate source is "acme"`,
+				`server.tls.acme.renew_before: invalid duration "30days"`,
+				`server.listeners.health_check.tls.source: certificate source "acme" is not supported by the health_check listener, override its TLS in listeners.health_check.tls`,
+			},
+		},
+		{
+			name: "ACME certificate source of the shared TLS requires the gRPC listener to override it",
+			modify: func(cfg *Config) {
+				cfg.Server.TLS.Source = CertSourceACME
+				cfg.Server.TLS.ACME = ACME{
+					Domains:  []string{"example.com"},
+					CacheDir: "/var/cache/acme",
+					HTTPPort: 80,
+				}
+			},
+			want: []string{
+				`server.tls.source: certificate source "acme" is not supported by the grpc listener, override its TLS in listeners.grpc.tls`,
+			},
+		},
+		{
+			name: "Logging is validated",
+			modify: func(cfg *Config) {
+				cfg.Logging = Logging{
+					Level:     "trace",
+					Format:    "logfmt",
+					Output:    "file",
+					File:      LogFile{MaxBackups: -1},
+					LevelPath: "/healthz",
+				}
+			},
+			want: []string{
+				`logging.level: unsupported level "trace", must be "debug", "info", "warn" or "error"`,
+				`logging.format: unsupported format "logfmt", must be "text" or "json"`,
+				`logging.file.path: required when the output is "file"`,
+				`logging.file.max_backups: invalid number -1, must not be negative`,
+				`logging.level_path: path "/healthz" collides with the health check paths`,
+			},
+		},
+		{
+			name: "Access log is validated when enabled",
+			modify: func(cfg *Config) {
+				cfg.Logging.Access = AccessLog{
+					Enabled:       true,
+					Format:        "text",
+					SamplingRatio: -0.1,
+					ExcludePaths:  []string{"/healthz", "healthz"},
+				}
+			},
+			want: []string{
+				`logging.access.format: unsupported format "text", must be "json" or "logfmt"`,
+				`logging.access.sampling_ratio: invalid ratio -0.1, must be between 0 and 1`,
+				`logging.access.exclude_paths: invalid path "healthz", must start with "/"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			verr, ok := err.(ValidationError)
+			if !ok {
+				t.Errorf("Validate() error = %v, want ValidationError", err)
+				return
+			}
+			got := make([]string, 0, len(verr))
+			for _, fe := range verr {
+				got = append(got, fe.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_Validate(t *testing.T) {
+	srv := validConfig().Server
+	srv.ProbeWaitTime = "3 seconds"
+
+	err := srv.Validate()
+	want := `invalid configuration: probe_wait_time: invalid duration "3 seconds"`
+	if err == nil || err.Error() != want {
+		t.Errorf("Validate() error = %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,183 @@
+package devcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultValidFor represents the default validity duration of the generated certificates
+	DefaultValidFor = time.Hour * 24 * 365
+
+	// CAName represents the common name of the development CA
+	CAName = "golang-server-template development CA"
+
+	// organization represents the subject organization of the generated certificates
+	organization = "golang-server-template development"
+
+	// clockSkew represents the duration which the certificates are valid before they are generated, so that the clock skew is tolerated
+	clockSkew = time.Hour
+)
+
+var (
+	// DefaultHosts represents the default hostnames and IP addresses of the server certificate
+	DefaultHosts = []string{"localhost", "127.0.0.1", "::1"}
+)
+
+// KeyPair represents the PEM encoded certificate and private key.
+type KeyPair struct {
+	// Cert represents the PEM encoded certificate.
+	Cert []byte
+
+	// Key represents the PEM encoded private key.
+	Key []byte
+}
+
+// CA represents the self-signed certificate authority which issues the server and client certificates.
+type CA struct {
+	KeyPair
+
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// NewCA returns the self-signed CA of the common name, which is valid for validFor.
+func NewCA(cn string, validFor time.Duration) (*CA, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the CA private key")
+	}
+	tmpl, err := template(cn, validFor)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.IsCA = true
+	tmpl.BasicConstraintsValid = true
+	tmpl.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create the CA certificate")
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	kp, err := encode(der, key)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{
+		KeyPair: kp,
+		cert:    cert,
+		key:     key,
+	}, nil
+}
+
+// Server returns the server certificate of the hosts, which are the hostnames or the IP addresses, issued by the CA.
+// The first host is the common name of the certificate.
+func (ca *CA) Server(hosts []string, validFor time.Duration) (KeyPair, error) {
+	if len(hosts) == 0 {
+		hosts = DefaultHosts
+	}
+	tmpl, err := template(hosts[0], validFor)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	return ca.issue(tmpl)
+}
+
+// Client returns the client certificate of the common name issued by the CA, which is used for the mutual TLS.
+func (ca *CA) Client(cn string, validFor time.Duration) (KeyPair, error) {
+	tmpl, err := template(cn, validFor)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	return ca.issue(tmpl)
+}
+
+// issue returns the certificate of tmpl signed by the CA, and its new private key.
+func (ca *CA) issue(tmpl *x509.Certificate) (KeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return KeyPair{}, errors.Wrap(err, "failed to generate the private key")
+	}
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return KeyPair{}, errors.Wrapf(err, "failed to create the certificate of %s", tmpl.Subject.CommonName)
+	}
+	return encode(der, key)
+}
+
+// TLSCertificate returns the tls.Certificate of the key pair.
+func (kp KeyPair) TLSCertificate() (tls.Certificate, error) {
+	return tls.X509KeyPair(kp.Cert, kp.Key)
+}
+
+// Write writes the certificate to "name.crt" and the private key to "name.key" in dir, the private key is readable only by the owner.
+// The dir is created when it does not exist.
+func (kp KeyPair) Write(dir, name string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, name+".crt"), kp.Cert, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, name+".key"), kp.Key, 0600)
+}
+
+// template returns the certificate template of the common name, which is valid for validFor from now.
+func template(cn string, validFor time.Duration) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate the serial number")
+	}
+	if validFor <= 0 {
+		validFor = DefaultValidFor
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   cn,
+			Organization: []string{organization},
+		},
+		NotBefore: now.Add(-clockSkew),
+		NotAfter:  now.Add(validFor),
+	}, nil
+}
+
+// encode returns the PEM encoded certificate and private key.
+func encode(der []byte, key *ecdsa.PrivateKey) (KeyPair, error) {
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	return KeyPair{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}),
+	}, nil
+}
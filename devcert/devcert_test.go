@@ -0,0 +1,106 @@
+package devcert
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCA(t *testing.T) {
+	ca, err := NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("NewCA() error = %v", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca.Cert) {
+		t.Fatal("NewCA() returns the invalid PEM certificate")
+	}
+
+	server, err := ca.Server(nil, time.Hour)
+	if err != nil {
+		t.Fatalf("Server() error = %v", err)
+	}
+	client, err := ca.Client("test-client", 0)
+	if err != nil {
+		t.Fatalf("Client() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		kp    KeyPair
+		host  string
+		usage x509.ExtKeyUsage
+		cn    string
+	}{
+		{
+			name:  "Server certificate of the default hostname",
+			kp:    server,
+			host:  "localhost",
+			usage: x509.ExtKeyUsageServerAuth,
+			cn:    "localhost",
+		},
+		{
+			name:  "Server certificate of the default IP address",
+			kp:    server,
+			host:  "::1",
+			usage: x509.ExtKeyUsageServerAuth,
+			cn:    "localhost",
+		},
+		{
+			name:  "Client certificate",
+			kp:    client,
+			usage: x509.ExtKeyUsageClientAuth,
+			cn:    "test-client",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crt, err := tt.kp.TLSCertificate()
+			if err != nil {
+				t.Fatalf("TLSCertificate() error = %v", err)
+			}
+			leaf, err := x509.ParseCertificate(crt.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if leaf.Subject.CommonName != tt.cn {
+				t.Errorf("common name = %q, want %q", leaf.Subject.CommonName, tt.cn)
+			}
+			_, err = leaf.Verify(x509.VerifyOptions{
+				DNSName:   tt.host,
+				Roots:     roots,
+				KeyUsages: []x509.ExtKeyUsage{tt.usage},
+			})
+			if err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyPair_Write(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "devcert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	ca, err := NewCA("test CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(tmp, "certs")
+	if err := ca.Write(dir, "ca"); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt")); err != nil || string(b) != string(ca.Cert) {
+		t.Errorf("Write() certificate = %s, error = %v", b, err)
+	}
+	fi, err := os.Stat(filepath.Join(dir, "ca.key"))
+	if err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("Write() private key = %v, error = %v, want the permission 0600", fi, err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package devcert generates a self-signed CA and the server and client certificates issued by it,
+// so that the server can serve TLS and mutual TLS in the local development without the hand-crafted certificate files.
+// The certificates must not be used in production.
+package devcert
@@ -11,8 +11,10 @@ type handler struct {
 	gs *grpc.Server
 }
 
-func New() Handler {
-	return nil
+func New(opts ...grpc.ServerOption) Handler {
+	return &handler{
+		gs: grpc.NewServer(opts...),
+	}
 }
 
 func (h *handler) GetGRPCServer() *grpc.Server {
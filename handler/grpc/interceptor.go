@@ -0,0 +1,39 @@
+package grpc
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// ChainUnaryServer returns the grpc.UnaryServerInterceptor which runs the interceptors in order, the first one is the outermost.
+// The grpc.UnaryInterceptor option accepts only one interceptor, so that the interceptors must be chained before it is set.
+func ChainUnaryServer(is ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		h := handler
+		for i := len(is) - 1; i >= 0; i-- {
+			h = func(interceptor grpc.UnaryServerInterceptor, next grpc.UnaryHandler) grpc.UnaryHandler {
+				return func(ctx context.Context, req interface{}) (interface{}, error) {
+					return interceptor(ctx, req, info, next)
+				}
+			}(is[i], h)
+		}
+		return h(ctx, req)
+	}
+}
+
+// ChainStreamServer returns the grpc.StreamServerInterceptor which runs the interceptors in order, the first one is the outermost.
+// The grpc.StreamInterceptor option accepts only one interceptor, so that the interceptors must be chained before it is set.
+func ChainStreamServer(is ...grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		h := handler
+		for i := len(is) - 1; i >= 0; i-- {
+			h = func(interceptor grpc.StreamServerInterceptor, next grpc.StreamHandler) grpc.StreamHandler {
+				return func(srv interface{}, ss grpc.ServerStream) error {
+					return interceptor(srv, ss, info, next)
+				}
+			}(is[i], h)
+		}
+		return h(srv, ss)
+	}
+}
@@ -0,0 +1,53 @@
+package grpc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+)
+
+func TestChainUnaryServer(t *testing.T) {
+	var got []string
+	interceptor := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			got = append(got, name)
+			return handler(ctx, req)
+		}
+	}
+
+	res, err := ChainUnaryServer(interceptor("first"), interceptor("second"))(context.Background(), "req", &grpc.UnaryServerInfo{},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = append(got, "handler")
+			return req, nil
+		})
+	if err != nil || res != "req" {
+		t.Errorf("ChainUnaryServer() = %v, %v, want %v, nil", res, err, "req")
+	}
+	if want := []string{"first", "second", "handler"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChainUnaryServer() order = %v, want %v", got, want)
+	}
+}
+
+func TestChainStreamServer(t *testing.T) {
+	var got []string
+	interceptor := func(name string) grpc.StreamServerInterceptor {
+		return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			got = append(got, name)
+			return handler(srv, ss)
+		}
+	}
+
+	err := ChainStreamServer(interceptor("first"), interceptor("second"))(nil, nil, &grpc.StreamServerInfo{},
+		func(srv interface{}, ss grpc.ServerStream) error {
+			got = append(got, "handler")
+			return nil
+		})
+	if err != nil {
+		t.Errorf("ChainStreamServer() error = %v", err)
+	}
+	if want := []string{"first", "second", "handler"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ChainStreamServer() order = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,158 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// ProblemJSON represents the content type of the RFC 7807 problem details
+	ProblemJSON = "application/problem+json"
+
+	// CodeInternal represents the machine-readable code of the error which is not *Error
+	CodeInternal = "internal"
+
+	// problemTypeBlank represents the problem type which has no additional semantics beyond the status code
+	problemTypeBlank = "about:blank"
+)
+
+// Error represents the HTTP error returned from Func.
+// The Status, Code, Message and Details are shown to the client, while Err is the internal cause which is only logged,
+// and it is shown to the client in the development mode.
+type Error struct {
+	// Status represents the HTTP status code.
+	Status int
+
+	// Code represents the machine-readable error code, e.g. "user_not_found".
+	Code string
+
+	// Type represents the URI which identifies the problem type, the default is "about:blank".
+	Type string
+
+	// Message represents the human readable explanation of the error, which is safe to show to the client.
+	Message string
+
+	// Details represents the additional information of the error, e.g. the invalid fields.
+	Details map[string]interface{}
+
+	// Err represents the internal cause of the error.
+	Err error
+}
+
+// Problem represents the RFC 7807 problem details object.
+type Problem struct {
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Status    int                    `json:"status"`
+	Detail    string                 `json:"detail,omitempty"`
+	Instance  string                 `json:"instance,omitempty"`
+	Code      string                 `json:"code,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// NewError returns *Error of the status code.
+func NewError(status int, code, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// WrapError returns *Error of the status code, which internal cause is err.
+func WrapError(err error, status int, code, message string) *Error {
+	return &Error{
+		Status:  status,
+		Code:    code,
+		Message: message,
+		Err:     err,
+	}
+}
+
+// Error returns the status code, message and the internal cause.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d %s: %s: %v", e.Status, e.Code, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%d %s: %s", e.Status, e.Code, e.Message)
+}
+
+// Unwrap returns the internal cause.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns *Error which has the details.
+func (e *Error) WithDetails(details map[string]interface{}) *Error {
+	e.Details = details
+	return e
+}
+
+// asError returns the first *Error in the chain of err, which is unwrapped by Cause of github.com/pkg/errors or by Unwrap.
+func asError(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		switch c := err.(type) {
+		case interface{ Cause() error }:
+			err = c.Cause()
+		case interface{ Unwrap() error }:
+			err = c.Unwrap()
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// NewProblem returns the Problem of err for the request r.
+// The first *Error in the chain of err, which is unwrapped by Cause or Unwrap, is rendered, and the error which has no *Error is treated as the internal server error.
+// The *Error which has no valid status code, e.g. &Error{Code: code}, is rendered as the internal server error of its code.
+// Unless expose is true, the text of the internal error is hidden from the client, so that the internals are not leaked in the production mode.
+func NewProblem(r *http.Request, err error, requestID string, expose bool) Problem {
+	e, ok := asError(err)
+	if !ok {
+		e = WrapError(err, http.StatusInternalServerError, CodeInternal, http.StatusText(http.StatusInternalServerError))
+	}
+
+	status := e.Status
+	if status < 100 || status > 599 {
+		// the invalid status code makes http.ResponseWriter.WriteHeader panic
+		status = http.StatusInternalServerError
+	}
+	p := Problem{
+		Type:      e.Type,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    e.Message,
+		Code:      e.Code,
+		RequestID: requestID,
+		Details:   e.Details,
+	}
+	if p.Type == "" {
+		p.Type = problemTypeBlank
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+	}
+	if expose && e.Err != nil {
+		details := make(map[string]interface{}, len(p.Details)+1)
+		for k, v := range p.Details {
+			details[k] = v
+		}
+		details["cause"] = e.Err.Error()
+		p.Details = details
+	}
+	return p
+}
+
+// WriteProblem writes the Problem as application/problem+json with its status code.
+func WriteProblem(w http.ResponseWriter, p Problem) error {
+	w.Header().Set("Content-Type", ProblemJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	return json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,129 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestNewProblem(t *testing.T) {
+	cause := errors.New("sql: connection refused")
+	tests := []struct {
+		name   string
+		err    error
+		expose bool
+		want   Problem
+	}{
+		{
+			name: "Typed error is rendered with its status and code",
+			err: NewError(http.StatusNotFound, "user_not_found", "user 42 is not found").
+				WithDetails(map[string]interface{}{"id": "42"}),
+			want: Problem{
+				Type:      problemTypeBlank,
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "user 42 is not found",
+				Instance:  "/users/42",
+				Code:      "user_not_found",
+				RequestID: "req",
+				Details:   map[string]interface{}{"id": "42"},
+			},
+		},
+		{
+			name: "Wrapped typed error is rendered with its status and code",
+			err:  errors.Wrap(fmt.Errorf("lookup failed: %w", NewError(http.StatusNotFound, "user_not_found", "user 42 is not found")), "GetUser failed"),
+			want: Problem{
+				Type:      problemTypeBlank,
+				Title:     "Not Found",
+				Status:    http.StatusNotFound,
+				Detail:    "user 42 is not found",
+				Instance:  "/users/42",
+				Code:      "user_not_found",
+				RequestID: "req",
+			},
+		},
+		{
+			name: "Typed error without the status code is rendered as internal server error",
+			err:  &Error{Code: "broken"},
+			want: Problem{
+				Type:      problemTypeBlank,
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Instance:  "/users/42",
+				Code:      "broken",
+				RequestID: "req",
+			},
+		},
+		{
+			name: "Internal error text is hidden in production",
+			err:  cause,
+			want: Problem{
+				Type:      problemTypeBlank,
+				Title:     "Internal Server Error",
+				Status:    http.StatusInternalServerError,
+				Detail:    "Internal Server Error",
+				Instance:  "/users/42",
+				Code:      CodeInternal,
+				RequestID: "req",
+			},
+		},
+		{
+			name:   "Internal error text is exposed in development",
+			err:    WrapError(cause, http.StatusServiceUnavailable, "db_unavailable", "database is unavailable"),
+			expose: true,
+			want: Problem{
+				Type:      problemTypeBlank,
+				Title:     "Service Unavailable",
+				Status:    http.StatusServiceUnavailable,
+				Detail:    "database is unavailable",
+				Instance:  "/users/42",
+				Code:      "db_unavailable",
+				RequestID: "req",
+				Details:   map[string]interface{}{"cause": "sql: connection refused"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewProblem(httptest.NewRequest(http.MethodGet, "/users/42", nil), tt.err, "req", tt.expose)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewProblem() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteProblem(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := WriteProblem(rec, Problem{
+		Type:   problemTypeBlank,
+		Title:  "Not Found",
+		Status: http.StatusNotFound,
+	})
+	if err != nil {
+		t.Fatalf("WriteProblem() error = %v", err)
+	}
+
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != ProblemJSON {
+		t.Errorf("WriteProblem() code = %d, Content-Type = %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var got map[string]interface{}
+	if err = json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got["title"] != "Not Found" || got["type"] != problemTypeBlank {
+		t.Errorf("WriteProblem() body = %s, error = %v", rec.Body.String(), err)
+	}
+}
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("cause")
+	if got := WrapError(cause, http.StatusInternalServerError, CodeInternal, "failed").Unwrap(); got != cause {
+		t.Errorf("Unwrap() = %v, want %v", got, cause)
+	}
+	if got := NewError(http.StatusNotFound, "not_found", "not found").Unwrap(); got != nil {
+		t.Errorf("Unwrap() = %v, want nil", got)
+	}
+}
@@ -14,7 +14,7 @@ type handler struct {
 }
 
 func New() Handler {
-	return nil
+	return new(handler)
 }
 
 func (h *handler) Sample(http.ResponseWriter, *http.Request) error {
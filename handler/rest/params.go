@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+type paramsKey struct{}
+
+// WithParams returns a copy of ctx which carries the path parameters of the matched route.
+func WithParams(ctx context.Context, params map[string]string) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// Params returns the path parameters of the request, keyed by the parameter name in the route pattern.
+// It returns nil when the route has no parameter. The returned map must not be modified.
+func Params(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return params
+}
+
+// Param returns the path parameter of the name, or empty string when the route has no such parameter.
+// e.g. Param(r, "id") returns "42" for the request "/users/42" of the route pattern "/users/{id}".
+func Param(r *http.Request, name string) string {
+	return Params(r)[name]
+}
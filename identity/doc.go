@@ -0,0 +1,2 @@
+// Package identity carries the identity of the client verified by the mutual TLS, to the REST handlers and the gRPC handlers through the request context.
+package identity
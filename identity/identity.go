@@ -0,0 +1,168 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity represents the client identity of the verified TLS client certificate.
+type Identity struct {
+	// Subject represents the distinguished name of the certificate subject, e.g. "CN=client,O=example".
+	Subject string
+
+	// CommonName represents the common name of the certificate subject.
+	CommonName string
+
+	// DNSNames represents the DNS names of the subject alternative names.
+	DNSNames []string
+
+	// URIs represents the URIs of the subject alternative names, e.g. the SPIFFE ID.
+	URIs []string
+
+	// EmailAddresses represents the email addresses of the subject alternative names.
+	EmailAddresses []string
+
+	// IPAddresses represents the IP addresses of the subject alternative names.
+	IPAddresses []string
+
+	// Certificate represents the verified client certificate.
+	Certificate *x509.Certificate
+}
+
+type identityKey struct{}
+
+// New returns the Identity of the certificate.
+func New(cert *x509.Certificate) *Identity {
+	id := &Identity{
+		Subject:        cert.Subject.String(),
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Certificate:    cert,
+	}
+	for _, u := range cert.URIs {
+		id.URIs = append(id.URIs, u.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		id.IPAddresses = append(id.IPAddresses, ip.String())
+	}
+	return id
+}
+
+// SANs returns all subject alternative names of the identity.
+func (id *Identity) SANs() []string {
+	sans := make([]string, 0, len(id.DNSNames)+len(id.URIs)+len(id.EmailAddresses)+len(id.IPAddresses))
+	sans = append(sans, id.DNSNames...)
+	sans = append(sans, id.URIs...)
+	sans = append(sans, id.EmailAddresses...)
+	return append(sans, id.IPAddresses...)
+}
+
+// WithIdentity returns a copy of ctx which carries the identity.
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity in ctx, or false when the client is not verified by the mutual TLS.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok && id != nil
+}
+
+// Middleware returns the HTTP middleware which stores the identity of the verified client certificate in the request context.
+// The certificate which is sent but not verified, e.g. by the "request" client auth mode, is not treated as the identity.
+func Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := fromState(r.TLS); id != nil {
+				r = r.WithContext(WithIdentity(r.Context(), id))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// UnaryServerInterceptor returns the grpc.UnaryServerInterceptor which stores the identity of the verified client certificate in the context.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		return handler(fromPeer(ctx), req)
+	}
+}
+
+// StreamServerInterceptor returns the grpc.StreamServerInterceptor which stores the identity of the verified client certificate in the context.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := fromPeer(ss.Context())
+		if ctx == ss.Context() {
+			return handler(srv, ss)
+		}
+		return handler(srv, &stream{
+			ServerStream: ss,
+			ctx:          ctx,
+		})
+	}
+}
+
+// fromPeer returns the context which carries the identity of the gRPC peer, or ctx itself when the peer is not verified.
+// The peer of the gRPC-Web request carries the TLS state of the HTTP request as well.
+func fromPeer(ctx context.Context) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if id := fromState(&info.State); id != nil {
+		return WithIdentity(ctx, id)
+	}
+	return ctx
+}
+
+// fromState returns the identity of the verified leaf certificate, or nil when the client is not verified.
+func fromState(state *tls.ConnectionState) *Identity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return New(state.VerifiedChains[0][0])
+}
+
+// stream replaces the context of the grpc.ServerStream with the one carrying the identity.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+// Match reports whether name matches the pattern, which "*" matches any sequence of characters, including "." and "/".
+// e.g. "*.example.com" matches "api.example.com", and "spiffe://example.org/ns/*" matches "spiffe://example.org/ns/default/sa/client".
+func Match(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && strings.HasSuffix(name, last)
+}
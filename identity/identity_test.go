@@ -0,0 +1,129 @@
+package identity
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+func clientCert() *x509.Certificate {
+	u, _ := url.Parse("spiffe://example.org/ns/default/sa/client")
+	return &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   "client",
+			Organization: []string{"example"},
+		},
+		DNSNames:    []string{"client.example.com"},
+		URIs:        []*url.URL{u},
+		IPAddresses: []net.IP{net.IPv4(192, 0, 2, 1)},
+	}
+}
+
+func TestNew(t *testing.T) {
+	id := New(clientCert())
+	if id.Subject != "CN=client,O=example" || id.CommonName != "client" {
+		t.Errorf("New() subject = %q, common name = %q", id.Subject, id.CommonName)
+	}
+	want := []string{"client.example.com", "spiffe://example.org/ns/default/sa/client", "192.0.2.1"}
+	if got := id.SANs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("SANs() = %v, want %v", got, want)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "client", name: "client", want: true},
+		{pattern: "client", name: "client2", want: false},
+		{pattern: "*.example.com", name: "api.example.com", want: true},
+		{pattern: "*.example.com", name: "example.com", want: false},
+		{pattern: "spiffe://example.org/ns/*", name: "spiffe://example.org/ns/default/sa/client", want: true},
+		{pattern: "CN=client-*,O=example", name: "CN=client-1,O=example", want: true},
+		{pattern: "a*b*c", name: "abc", want: true},
+		{pattern: "a*b*c", name: "acb", want: false},
+		{pattern: "ab*ba", name: "aba", want: false},
+		{pattern: "*", name: "", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := Match(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  bool
+	}{
+		{
+			name: "Plaintext request has no identity",
+		},
+		{
+			name: "Unverified certificate is not the identity",
+			state: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{clientCert()},
+			},
+		},
+		{
+			name: "Verified certificate is the identity",
+			state: &tls.ConnectionState{
+				PeerCertificates: []*x509.Certificate{clientCert()},
+				VerifiedChains:   [][]*x509.Certificate{{clientCert()}},
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *Identity
+			var ok bool
+			h := Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, ok = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = tt.state
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if ok != tt.want || (ok && got.CommonName != "client") {
+				t.Errorf("FromContext() = %v, %v, want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{
+				VerifiedChains: [][]*x509.Certificate{{clientCert()}},
+			},
+		},
+	})
+	var got *Identity
+	var ok bool
+	UnaryServerInterceptor()(ctx, nil, new(grpc.UnaryServerInfo), func(ctx context.Context, req interface{}) (interface{}, error) {
+		got, ok = FromContext(ctx)
+		return nil, nil
+	})
+	if !ok || got.Subject != "CN=client,O=example" {
+		t.Errorf("FromContext() = %v, %v, want the identity", got, ok)
+	}
+}
@@ -0,0 +1,3 @@
+// Package response records the status code and the size of the response written by the REST handlers,
+// which is shared by the access log, the metrics and the tracing middlewares.
+package response
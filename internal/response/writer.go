@@ -0,0 +1,51 @@
+package response
+
+import "net/http"
+
+// Writer records the status code and the bytes written to the http.ResponseWriter.
+type Writer struct {
+	http.ResponseWriter
+	code        int
+	n           int64
+	wroteHeader bool
+}
+
+// NewWriter returns Writer wrapping w, which status code is 200 until another one is written.
+func NewWriter(w http.ResponseWriter) *Writer {
+	return &Writer{
+		ResponseWriter: w,
+		code:           http.StatusOK,
+	}
+}
+
+// Status returns the status code written to the response.
+func (w *Writer) Status() int {
+	return w.code
+}
+
+// Size returns the bytes of the response body written so far.
+func (w *Writer) Size() int64 {
+	return w.n
+}
+
+func (w *Writer) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.code = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *Writer) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher when the underlying http.ResponseWriter supports it.
+func (w *Writer) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
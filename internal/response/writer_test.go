@@ -0,0 +1,55 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+		wantSize int64
+	}{
+		{
+			name: "Status is 200 when only the body is written",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			wantCode: http.StatusOK,
+			wantSize: 2,
+		},
+		{
+			name: "First written status is recorded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("not found"))
+			},
+			wantCode: http.StatusNotFound,
+			wantSize: 9,
+		},
+		{
+			name: "Status written after the body is ignored",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+				w.WriteHeader(http.StatusInternalServerError)
+				w.(http.Flusher).Flush()
+			},
+			wantCode: http.StatusOK,
+			wantSize: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			w := NewWriter(rec)
+			tt.handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Status() != tt.wantCode || w.Size() != tt.wantSize {
+				t.Errorf("Status() = %d, Size() = %d, want %d, %d", w.Status(), w.Size(), tt.wantCode, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+// Package logger provides the leveled logger of the server, which writes the text or JSON lines of kpango/glg to the standard output or the rotated file.
+// The level can be changed at runtime through the admin endpoint served by Logger.Handler.
+package logger
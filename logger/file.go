@@ -0,0 +1,135 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// defaultMaxSizeMB represents the maximum size of the log file when config.LogFile.MaxSizeMB is 0
+	defaultMaxSizeMB = 100
+
+	// defaultMaxBackups represents the number of the rotated files kept when config.LogFile.MaxBackups is 0
+	defaultMaxBackups = 3
+)
+
+// rotatingFile represents the log file, which is renamed to "<path>.1" when it exceeds the maximum size,
+// and the older rotated files are shifted to "<path>.2", "<path>.3" and so on, up to the maximum backups.
+// It is not safe for concurrent use, the writes are serialized by syncWriter.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func newRotatingFile(cfg config.LogFile) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSizeMB) << 20,
+		maxBackups: cfg.MaxBackups,
+	}
+	if r.maxSize == 0 {
+		r.maxSize = defaultMaxSizeMB << 20
+	}
+	if r.maxBackups == 0 {
+		r.maxBackups = defaultMaxBackups
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Write writes b to the current file after the rotation when b exceeds the maximum size.
+// When the rotation fails, b is still written to the reopened current file, and the error of the rotation is returned.
+func (r *rotatingFile) Write(b []byte) (int, error) {
+	var rerr error
+	switch {
+	case r.f == nil:
+		rerr = r.open()
+	case r.size > 0 && r.size+int64(len(b)) > r.maxSize:
+		rerr = r.rotate()
+	}
+	if r.f == nil {
+		return 0, rerr
+	}
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	if err == nil {
+		err = rerr
+	}
+	return n, err
+}
+
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	return r.f.Close()
+}
+
+// open opens the log file for appending, and reads its current size.
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	return nil
+}
+
+// rotate closes the current file, shifts the rotated files, and opens the new file.
+// When the rotated files cannot be shifted, the current file is reopened, so that the later writes are appended to it and retry the rotation.
+// r.f is nil when the file cannot be reopened, and the next write retries to open it.
+func (r *rotatingFile) rotate() error {
+	err := r.f.Close()
+	r.f = nil
+	if err != nil {
+		return err
+	}
+	err = r.shift()
+	if err != nil {
+		oerr := r.open()
+		if oerr != nil {
+			return errors.Wrapf(oerr, "failed to reopen the log file after the rotation failure: %v", err)
+		}
+		return err
+	}
+	return r.open()
+}
+
+// shift removes the oldest rotated file, and renames the rotated files and the current file to the next backup path.
+func (r *rotatingFile) shift() error {
+	err := os.Remove(backupPath(r.path, r.maxBackups))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for i := r.maxBackups - 1; i > 0; i-- {
+		err = os.Rename(backupPath(r.path, i), backupPath(r.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = os.Rename(r.path, backupPath(r.path, 1))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// backupPath returns the path of the i-th rotated file.
+func backupPath(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
@@ -0,0 +1,105 @@
+package logger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kpango/golang-server-template/config"
+)
+
+func Test_rotatingFile_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.log")
+	r, err := newRotatingFile(config.LogFile{
+		Path:       path,
+		MaxBackups: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	// the size is lowered from the minimum of the configuration, 1 MB
+	r.maxSize = 10
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	for name, want := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		b, err := ioutil.ReadFile(name)
+		if err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), b, err, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists, want removed: %v", filepath.Base(path), err)
+	}
+
+	matches, err := filepath.Glob(path + "*")
+	if err != nil || len(matches) != 3 {
+		t.Errorf("log files = %s, want 3 files", strings.Join(matches, ", "))
+	}
+}
+
+func Test_rotatingFile_Write_rotationFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "server.log")
+	r, err := newRotatingFile(config.LogFile{
+		Path:       path,
+		MaxBackups: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	r.maxSize = 10
+
+	// the rotated file cannot be removed while it is the non-empty directory
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("first\n")); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if n, err := r.Write([]byte("second\n")); err == nil || n != len("second\n") {
+		t.Errorf("Write() during the rotation failure = %d, %v, want %d and error", n, err, len("second\n"))
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "first\nsecond\n" {
+		t.Errorf("%s = %q, %v, want %q", filepath.Base(path), b, err, "first\nsecond\n")
+	}
+
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("third\n")); err != nil {
+		t.Fatalf("Write() after the rotation failure error = %v", err)
+	}
+	for name, want := range map[string]string{
+		path:        "third\n",
+		path + ".1": "first\nsecond\n",
+	} {
+		b, err := ioutil.ReadFile(name)
+		if err != nil || string(b) != want {
+			t.Errorf("%s = %q, %v, want %q", filepath.Base(name), b, err, want)
+		}
+	}
+}
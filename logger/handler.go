@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// levelBody represents the request and response body of the admin endpoint.
+type levelBody struct {
+	Level string `json:"level"`
+}
+
+// Handler returns the http.Handler of the admin endpoint of the level.
+// GET responds the current level as {"level":"info"}, and PUT changes the level to the one of the request body in the same form.
+func Handler(l Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut:
+			var body levelBody
+			err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&body)
+			if err != nil {
+				http.Error(w, "invalid request body, must be {\"level\":\"<level>\"}", http.StatusBadRequest)
+				return
+			}
+			lv, err := ParseLevel(strings.TrimSpace(body.Level))
+			if err != nil || body.Level == "" {
+				http.Error(w, "unsupported level, must be \"debug\", \"info\", \"warn\" or \"error\"", http.StatusBadRequest)
+				return
+			}
+			old := l.Level()
+			l.SetLevel(lv)
+			l.Warnf("log level is changed from %s to %s", old, lv)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+		if r.Method == http.MethodHead {
+			return
+		}
+		err := json.NewEncoder(w).Encode(levelBody{
+			Level: l.Level().String(),
+		})
+		if err != nil {
+			l.Errorf("failed to write the log level: %v", err)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		wantCode  int
+		wantLevel Level
+	}{
+		{
+			name:      "GET responds the current level",
+			method:    http.MethodGet,
+			wantCode:  http.StatusOK,
+			wantLevel: InfoLevel,
+		},
+		{
+			name:      "PUT changes the level",
+			method:    http.MethodPut,
+			body:      `{"level":"debug"}`,
+			wantCode:  http.StatusOK,
+			wantLevel: DebugLevel,
+		},
+		{
+			name:      "PUT of the unsupported level is rejected",
+			method:    http.MethodPut,
+			body:      `{"level":"trace"}`,
+			wantCode:  http.StatusBadRequest,
+			wantLevel: InfoLevel,
+		},
+		{
+			name:      "PUT of the invalid body is rejected",
+			method:    http.MethodPut,
+			body:      `debug`,
+			wantCode:  http.StatusBadRequest,
+			wantLevel: InfoLevel,
+		},
+		{
+			name:      "DELETE is not allowed",
+			method:    http.MethodDelete,
+			wantCode:  http.StatusMethodNotAllowed,
+			wantLevel: InfoLevel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewWriter(ioutil.Discard, InfoLevel, false, false)
+			rec := httptest.NewRecorder()
+			Handler(l).ServeHTTP(rec, httptest.NewRequest(tt.method, "/loglevel", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("Handler() code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if l.Level() != tt.wantLevel {
+				t.Errorf("Level() = %v, want %v", l.Level(), tt.wantLevel)
+			}
+			if want := `{"level":"` + tt.wantLevel.String() + `"}` + "\n"; rec.Code == http.StatusOK && rec.Body.String() != want {
+				t.Errorf("Handler() body = %q, want %q", rec.Body.String(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,256 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kpango/glg"
+	"github.com/kpango/golang-server-template/config"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FormatText represents the log format of the human readable text lines, which is the default format
+	FormatText = "text"
+
+	// FormatJSON represents the log format of JSON lines
+	FormatJSON = "json"
+
+	// OutputStdout represents the log output of the standard output, which is the default output
+	OutputStdout = "stdout"
+
+	// OutputFile represents the log output of the rotated file
+	OutputFile = "file"
+
+	// callerDepth represents the depth of the caller of Logger from the glg output, which is shown in the error lines.
+	// It skips the glg output, the glg level method, logger.log and the Logger method.
+	callerDepth = 4
+)
+
+// Level represents the severity of the log line.
+type Level int32
+
+const (
+	// DebugLevel represents the verbose information for debugging.
+	DebugLevel Level = iota
+
+	// InfoLevel represents the information of the normal operation, which is the default level.
+	InfoLevel
+
+	// WarnLevel represents the unexpected event which the server recovers from.
+	WarnLevel
+
+	// ErrorLevel represents the failure of a request or an operation.
+	ErrorLevel
+)
+
+// levelNames represents the names of the levels used in the configuration.
+var levelNames = [...]string{
+	DebugLevel: "debug",
+	InfoLevel:  "info",
+	WarnLevel:  "warn",
+	ErrorLevel: "error",
+}
+
+// glgLevels represents the glg levels, which the lines of the levels are written by.
+var glgLevels = [...]glg.LEVEL{
+	DebugLevel: glg.DEBG,
+	InfoLevel:  glg.INFO,
+	WarnLevel:  glg.WARN,
+	ErrorLevel: glg.ERR,
+}
+
+// glgColors represents the colors of the level tags in the colored text lines.
+var glgColors = [...]func(string) string{
+	DebugLevel: glg.Purple,
+	InfoLevel:  glg.Green,
+	WarnLevel:  glg.Orange,
+	ErrorLevel: glg.Red,
+}
+
+// Logger represents the leveled logger.
+type Logger interface {
+	// Debugf logs the formatted message at DebugLevel.
+	Debugf(format string, args ...interface{})
+
+	// Infof logs the formatted message at InfoLevel.
+	Infof(format string, args ...interface{})
+
+	// Warnf logs the formatted message at WarnLevel.
+	Warnf(format string, args ...interface{})
+
+	// Errorf logs the formatted message at ErrorLevel.
+	Errorf(format string, args ...interface{})
+
+	// Level returns the current level, the lines below it are discarded.
+	Level() Level
+
+	// SetLevel changes the level at runtime.
+	SetLevel(lv Level)
+
+	// Writer returns the log output, which is shared with the other logs such as the access log.
+	Writer() io.Writer
+
+	// Close closes the log output when it is a file.
+	Close() error
+}
+
+type logger struct {
+	glg   *glg.Glg
+	out   *syncWriter
+	level int32
+}
+
+// New returns the Logger defined in cfg.
+func New(cfg config.Logging) (Logger, error) {
+	lv, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer
+	switch cfg.Output {
+	case "", OutputStdout:
+		w = os.Stdout
+	case OutputFile:
+		w, err = newRotatingFile(cfg.File)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to open the log file")
+		}
+	default:
+		return nil, errors.Errorf("unsupported log output %q", cfg.Output)
+	}
+
+	switch cfg.Format {
+	case "", FormatText, FormatJSON:
+	default:
+		return nil, errors.Errorf("unsupported log format %q", cfg.Format)
+	}
+
+	return NewWriter(w, lv, cfg.Format == FormatJSON, cfg.Color), nil
+}
+
+// NewWriter returns the Logger which writes the JSON lines or the text lines of lv and above to w by glg.
+// The level tags of the text lines are colored when color is true.
+func NewWriter(w io.Writer, lv Level, json, color bool) Logger {
+	out := &syncWriter{w: w}
+	g := glg.New().
+		SetMode(glg.WRITER).
+		SetWriter(out).
+		SetCallerDepth(callerDepth)
+	if json {
+		g.EnableJSON()
+	} else if color {
+		// glg colors the lines only when it writes to the standard output, so the level tags of w are colored instead
+		for i, gl := range glgLevels {
+			g.SetPrefix(gl, glgColors[i](gl.String()))
+		}
+	}
+	return &logger{
+		glg:   g,
+		out:   out,
+		level: int32(lv),
+	}
+}
+
+// Nop returns the Logger which discards all lines, e.g. for tests.
+func Nop() Logger {
+	return NewWriter(ioutil.Discard, ErrorLevel+1, false, false)
+}
+
+// ParseLevel returns the Level of the name, "debug", "info", "warn" or "error".
+// The empty name is InfoLevel.
+func ParseLevel(name string) (Level, error) {
+	if name == "" {
+		return InfoLevel, nil
+	}
+	for lv, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(lv), nil
+		}
+	}
+	return InfoLevel, errors.Errorf("unsupported log level %q", name)
+}
+
+// String returns the name of the level.
+func (lv Level) String() string {
+	if lv < DebugLevel || int(lv) >= len(levelNames) {
+		return fmt.Sprintf("Level(%d)", lv)
+	}
+	return levelNames[lv]
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.log(DebugLevel, format, args...)
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.log(InfoLevel, format, args...)
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.log(WarnLevel, format, args...)
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.log(ErrorLevel, format, args...)
+}
+
+func (l *logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.level))
+}
+
+func (l *logger) SetLevel(lv Level) {
+	atomic.StoreInt32(&l.level, int32(lv))
+}
+
+func (l *logger) Writer() io.Writer {
+	return l.out
+}
+
+func (l *logger) Close() error {
+	if c, ok := l.out.w.(io.Closer); ok && l.out.w != os.Stdout {
+		return c.Close()
+	}
+	return nil
+}
+
+// log writes the line of lv by glg, unless lv is below the current level.
+// The write error is reported to the standard error, since there is no other place to log it.
+func (l *logger) log(lv Level, format string, args ...interface{}) {
+	if lv < l.Level() {
+		return
+	}
+	var log func(format string, val ...interface{}) error
+	switch lv {
+	case DebugLevel:
+		log = l.glg.Debugf
+	case InfoLevel:
+		log = l.glg.Infof
+	case WarnLevel:
+		log = l.glg.Warnf
+	default:
+		log = l.glg.Errorf
+	}
+	err := log("%s", strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write the log: %v\n", err)
+	}
+}
+
+// syncWriter serializes the writes to the log output, so that the lines of the logger and the other logs are not interleaved.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *syncWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(b)
+}
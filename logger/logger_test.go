@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Level
+		wantErr bool
+	}{
+		{name: "", want: InfoLevel},
+		{name: "debug", want: DebugLevel},
+		{name: "WARN", want: WarnLevel},
+		{name: "error", want: ErrorLevel},
+		{name: "trace", want: InfoLevel, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLevel(tt.name)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseLevel() = %v, %v, want %v, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_logger(t *testing.T) {
+	tests := []struct {
+		name  string
+		json  bool
+		color bool
+		level Level
+		log   func(Logger)
+		want  []string
+	}{
+		{
+			name:  "Lines below the level are discarded",
+			level: WarnLevel,
+			log: func(l Logger) {
+				l.Debugf("debug")
+				l.Infof("info")
+				l.Warnf("warn %d", 1)
+				l.Errorf("error %d", 2)
+			},
+			want: []string{`\t\[WARN\]:\twarn 1$`, `\t\[ERR\]:\t\(logger_test\.go:\d+\):\terror 2$`},
+		},
+		{
+			name:  "Level tag is colored",
+			color: true,
+			level: InfoLevel,
+			log: func(l Logger) {
+				l.Errorf("error")
+			},
+			want: []string{`\t\[\x1b\[31mERR\x1b\[39m\]:\t\(logger_test\.go:\d+\):\terror$`},
+		},
+		{
+			name:  "Lines are written as JSON",
+			json:  true,
+			color: true,
+			level: DebugLevel,
+			log: func(l Logger) {
+				l.Debugf("debug \"quoted\"")
+			},
+			want: []string{`"level":"DEBG","detail":"debug \\"quoted\\""}$`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			tt.log(NewWriter(buf, tt.level, tt.json, tt.color))
+
+			lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("log = %q, want %q", buf.String(), tt.want)
+			}
+			for i, line := range lines {
+				if !regexp.MustCompile(tt.want[i]).MatchString(line) {
+					t.Errorf("log line = %q, want match %q", line, tt.want[i])
+				}
+				if tt.json && !json.Valid([]byte(line)) {
+					t.Errorf("log line = %q, want JSON", line)
+				}
+			}
+		})
+	}
+}
+
+func Test_logger_SetLevel(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := NewWriter(buf, InfoLevel, false, false)
+	l.Debugf("discarded")
+	l.SetLevel(DebugLevel)
+	l.Debugf("written")
+
+	if l.Level() != DebugLevel {
+		t.Errorf("Level() = %v, want %v", l.Level(), DebugLevel)
+	}
+	if strings.Contains(buf.String(), "discarded") || !strings.Contains(buf.String(), "written") {
+		t.Errorf("log = %q", buf.String())
+	}
+}
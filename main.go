@@ -7,44 +7,76 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/kpango/glg"
 	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
 	"github.com/kpango/golang-server-template/usecase"
 	"github.com/pkg/errors"
 )
 
 type params struct {
-	configFilePath string
-	showVersion    bool
+	configFilePaths paths
+	showVersion     bool
+	printConfig     bool
+	watchInterval   time.Duration
+	envPrefix       string
+}
+
+// paths represents the file paths of a repeatable flag
+type paths []string
+
+func (p *paths) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *paths) Set(path string) error {
+	*p = append(*p, path)
+	return nil
 }
 
 func parseParams() (*params, error) {
 	p := new(params)
 	f := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
-	f.StringVar(&p.configFilePath,
+	f.Var(&p.configFilePaths,
 		"f",
-		"/etc/server/config.yaml",
-		"tenant config yaml file path")
+		"tenant config file path (yaml, json or toml), it can be repeated to merge the files in order (default /etc/server/config.yaml)")
 	f.BoolVar(&p.showVersion,
 		"version",
 		false,
 		"show server version")
+	f.BoolVar(&p.printConfig,
+		"print-config",
+		false,
+		"print the effective configuration with secrets redacted, and exit")
+	f.DurationVar(&p.watchInterval,
+		"watch-interval",
+		time.Second*5,
+		"interval to check the config file change, 0 disables the hot reload on file change")
+	f.StringVar(&p.envPrefix,
+		"env-prefix",
+		config.EnvPrefix,
+		"prefix of the environment variables which override the config fields, e.g. APP_SERVER_HTTP_PORT")
 
 	err := f.Parse(os.Args[1:])
 	if err != nil {
 		return nil, errors.Wrap(err, "Parse Failed")
 	}
 
+	if len(p.configFilePaths) == 0 {
+		p.configFilePaths = paths{"/etc/server/config.yaml"}
+	}
+
 	return p, nil
 }
 
-func run(cfg config.Config) []error {
+func run(p *params, cfg config.Config, log logger.Logger) []error {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	daemon, err := usecase.New(cfg)
+	daemon, err := usecase.New(cfg, log)
 	if err != nil {
 		return []error{err}
 	}
@@ -53,14 +85,33 @@ func run(cfg config.Config) []error {
 
 	sigCh := make(chan os.Signal, 1)
 
-	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT)
+	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	var wch <-chan struct{}
+	if p.watchInterval > 0 {
+		wch = config.Watch(ctx, p.watchInterval, p.configFilePaths...)
+	}
 
 	for {
 		select {
-		case <-sigCh:
-			close(sigCh)
-			glg.Warn("server shutdown...")
+		case sig := <-sigCh:
+			if sig == syscall.SIGHUP {
+				reload(p.configFilePaths, daemon, log)
+				continue
+			}
+			signal.Stop(sigCh)
+			log.Warnf("server shutdown...")
+
+			// wait for the servers to shut down gracefully, and the queued spans to be exported
+			cancel()
+			for _, err := range <-ech {
+				if err != context.Canceled {
+					log.Errorf("%v", err)
+				}
+			}
 			return nil
+		case <-wch:
+			reload(p.configFilePaths, daemon, log)
 		case errs := <-ech:
 			close(ech)
 			return errs
@@ -68,44 +119,116 @@ func run(cfg config.Config) []error {
 	}
 }
 
+// reload reads the configuration files again and applies it to the running daemon.
+// When the configuration is invalid, the previous configuration keeps running.
+func reload(paths []string, daemon usecase.Runner, log logger.Logger) {
+	log.Infof("reloading configuration %s", strings.Join(paths, ", "))
+
+	cfg, notes, err := config.Load(paths...)
+	if err != nil {
+		log.Errorf("failed to reload configuration, keep running with the previous configuration: %v", err)
+		return
+	}
+
+	for _, n := range notes {
+		log.Infof("%s", n)
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		log.Errorf("failed to reload configuration, keep running with the previous configuration: %v", err)
+		return
+	}
+
+	err = daemon.Reload(*cfg)
+	if err != nil {
+		log.Errorf("failed to reload configuration, keep running with the previous configuration: %v", err)
+		return
+	}
+
+	log.Infof("configuration reloaded")
+}
+
 func main() {
+	// the bootstrap logger is used until the logging configuration is loaded
+	var log logger.Logger = logger.NewWriter(os.Stdout, logger.InfoLevel, false, false)
+	fatal := func(err interface{}) {
+		log.Errorf("%v", err)
+		log.Close()
+		os.Exit(1)
+	}
+
 	defer func() {
 		if err := recover(); err != nil {
 			if _, ok := err.(runtime.Error); ok {
 				panic(err)
 			}
-			glg.Error(err)
+			log.Errorf("%v", err)
 		}
 	}()
 
-	// Docker環境においては色出力の意味がないため無効にする
-	glg.Get().DisableColor()
+	if len(os.Args) > 1 && os.Args[1] == certsCommand {
+		cp, err := parseCertsParams(os.Args[2:])
+		if err != nil {
+			fatal(err)
+			return
+		}
+		files, err := generateCerts(cp)
+		if err != nil {
+			fatal(err)
+			return
+		}
+		log.Infof("generated the development certificates %s", strings.Join(files, ", "))
+		return
+	}
 
 	p, err := parseParams()
 	if err != nil {
-		glg.Fatal(err)
+		fatal(err)
 		return
 	}
 
 	if p.showVersion {
-		glg.Infof("server version -> %s", config.GetVersion())
+		log.Infof("server version -> %s", config.GetVersion())
 		return
 	}
 
-	cfg, err := config.New(p.configFilePath)
+	config.EnvPrefix = p.envPrefix
+
+	cfg, notes, err := config.Load(p.configFilePaths...)
 	if err != nil {
-		glg.Fatal(err)
+		fatal(err)
 		return
 	}
 
-	if cfg.Version != config.GetVersion() {
-		glg.Fatal(errors.New("invalid configuration version"))
+	if p.printConfig {
+		err = cfg.Dump(os.Stdout)
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		fatal(err)
 		return
 	}
 
-	errs := run(*cfg)
+	log, err = logger.New(cfg.Logging)
+	if err != nil {
+		fatal(err)
+		return
+	}
+	defer log.Close()
+
+	for _, n := range notes {
+		log.Infof("%s", n)
+	}
+
+	errs := run(p, *cfg, log)
 	if errs != nil && len(errs) > 0 {
-		glg.Fatal(errs)
+		fatal(errs)
 		return
 	}
 }
@@ -0,0 +1,2 @@
+// Package metrics collects the Prometheus metrics of the REST, gRPC and gRPC-Web servers, and the Go runtime.
+package metrics
@@ -0,0 +1,304 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kpango/golang-server-template/internal/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Metrics represents the Prometheus collectors of the server.
+type Metrics interface {
+	// Handler returns the http.Handler which serves the metrics in the Prometheus exposition format.
+	Handler() http.Handler
+
+	// Registerer returns the prometheus.Registerer, so that any component can register its own collectors.
+	Registerer() prometheus.Registerer
+
+	// InstrumentHandler returns the http.Handler which records the request count, latency and in-flight requests of the route.
+	InstrumentHandler(route string, h http.Handler) http.Handler
+
+	// ObserveTimeout records the handler timeout of the route, and counts the handler as running after the timeout.
+	// The returned function must be called when the handler returns, so that the leaked handlers are observable.
+	ObserveTimeout(route, method string) (done func())
+
+	// ObservePanic records the panic recovered from the handler of the protocol, which is the route name or the full RPC method name.
+	ObservePanic(protocol, handler string)
+
+	// ObserveCertificateExpiry records the expiry of the TLS certificate served by the listener, so that the certificate rotation can be alerted before it expires.
+	ObserveCertificateExpiry(listener, certificate string, notAfter time.Time)
+
+	// UnaryServerInterceptor returns the grpc.UnaryServerInterceptor which records the unary RPCs.
+	UnaryServerInterceptor() grpc.UnaryServerInterceptor
+
+	// StreamServerInterceptor returns the grpc.StreamServerInterceptor which records the streaming RPCs.
+	StreamServerInterceptor() grpc.StreamServerInterceptor
+}
+
+// Protocol represents the protocol which the gRPC request is received by.
+type Protocol string
+
+const (
+	// ProtocolHTTP represents the REST API protocol
+	ProtocolHTTP Protocol = "http"
+
+	// ProtocolGRPC represents the native gRPC protocol
+	ProtocolGRPC Protocol = "grpc"
+
+	// ProtocolGRPCWeb represents the gRPC-Web protocol
+	ProtocolGRPCWeb Protocol = "grpc-web"
+)
+
+type metrics struct {
+	reg *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	httpInFlight *prometheus.GaugeVec
+	httpTimeouts *prometheus.CounterVec
+	httpTimedOut *prometheus.GaugeVec
+
+	panics *prometheus.CounterVec
+
+	certExpiry *prometheus.GaugeVec
+
+	grpcStarted  *prometheus.CounterVec
+	grpcHandled  *prometheus.CounterVec
+	grpcDuration *prometheus.HistogramVec
+	grpcInFlight *prometheus.GaugeVec
+	grpcMsgRecv  *prometheus.CounterVec
+	grpcMsgSent  *prometheus.CounterVec
+}
+
+type protocolKey struct{}
+
+// New returns Metrics, which registers the REST, gRPC and Go runtime collectors to a new registry.
+func New() (Metrics, error) {
+	grpcLabels := []string{"grpc_type", "grpc_service", "grpc_method", "protocol"}
+	m := &metrics{
+		reg: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of REST requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of REST requests by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		httpInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of REST requests currently being served by route and method.",
+		}, []string{"route", "method"}),
+		httpTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_timeouts_total",
+			Help: "Total number of REST requests which exceeded the handler timeout by route and method.",
+		}, []string{"route", "method"}),
+		httpTimedOut: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_timed_out_handlers",
+			Help: "Number of REST handlers still running after the handler timeout by route and method.",
+		}, []string{"route", "method"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "panics_recovered_total",
+			Help: "Total number of panics recovered from the handlers by protocol and handler.",
+		}, []string{"protocol", "handler"}),
+		certExpiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tls_certificate_expiry_timestamp_seconds",
+			Help: "Expiry of the current TLS certificate in seconds since the Unix epoch by listener and certificate.",
+		}, []string{"listener", "certificate"}),
+		grpcStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_started_total",
+			Help: "Total number of RPCs started on the server.",
+		}, grpcLabels),
+		grpcHandled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_handled_total",
+			Help: "Total number of RPCs completed on the server by status code.",
+		}, append(grpcLabels, "grpc_code")),
+		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_handling_seconds",
+			Help:    "Latency of RPCs handled by the server.",
+			Buckets: prometheus.DefBuckets,
+		}, grpcLabels),
+		grpcInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "grpc_server_in_flight",
+			Help: "Number of RPCs currently being handled by the server.",
+		}, grpcLabels),
+		grpcMsgRecv: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_received_total",
+			Help: "Total number of stream messages received by the server.",
+		}, grpcLabels),
+		grpcMsgSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_msg_sent_total",
+			Help: "Total number of stream messages sent by the server.",
+		}, grpcLabels),
+	}
+
+	for _, c := range []prometheus.Collector{
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.httpInFlight,
+		m.httpTimeouts,
+		m.httpTimedOut,
+		m.panics,
+		m.certExpiry,
+		m.grpcStarted,
+		m.grpcHandled,
+		m.grpcDuration,
+		m.grpcInFlight,
+		m.grpcMsgRecv,
+		m.grpcMsgSent,
+	} {
+		if err := m.reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// WithProtocol returns a copy of ctx which carries the protocol of the gRPC request.
+func WithProtocol(ctx context.Context, p Protocol) context.Context {
+	return context.WithValue(ctx, protocolKey{}, p)
+}
+
+// ProtocolFromContext returns the protocol stored by WithProtocol, the default is ProtocolGRPC.
+func ProtocolFromContext(ctx context.Context) Protocol {
+	if p, ok := ctx.Value(protocolKey{}).(Protocol); ok {
+		return p
+	}
+	return ProtocolGRPC
+}
+
+func (m *metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.reg, promhttp.HandlerOpts{})
+}
+
+func (m *metrics) Registerer() prometheus.Registerer {
+	return m.reg
+}
+
+func (m *metrics) InstrumentHandler(route string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight := m.httpInFlight.WithLabelValues(route, r.Method)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		sw := response.NewWriter(w)
+		h.ServeHTTP(sw, r)
+
+		m.httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		m.httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(sw.Status())).Inc()
+	})
+}
+
+func (m *metrics) ObserveTimeout(route, method string) func() {
+	m.httpTimeouts.WithLabelValues(route, method).Inc()
+	running := m.httpTimedOut.WithLabelValues(route, method)
+	running.Inc()
+	return running.Dec
+}
+
+func (m *metrics) ObservePanic(protocol, handler string) {
+	m.panics.WithLabelValues(protocol, handler).Inc()
+}
+
+func (m *metrics) ObserveCertificateExpiry(listener, certificate string, notAfter time.Time) {
+	m.certExpiry.WithLabelValues(listener, certificate).Set(float64(notAfter.Unix()))
+}
+
+func (m *metrics) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		labels := grpcLabelValues("unary", info.FullMethod, ProtocolFromContext(ctx))
+		done := m.startRPC(labels)
+
+		res, err := handler(ctx, req)
+		if err == nil {
+			m.grpcMsgRecv.WithLabelValues(labels...).Inc()
+			m.grpcMsgSent.WithLabelValues(labels...).Inc()
+		}
+		done(err)
+		return res, err
+	}
+}
+
+func (m *metrics) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		labels := grpcLabelValues(streamType(info), info.FullMethod, ProtocolFromContext(ss.Context()))
+		done := m.startRPC(labels)
+
+		err := handler(srv, &monitoredStream{
+			ServerStream: ss,
+			recv:         m.grpcMsgRecv.WithLabelValues(labels...),
+			sent:         m.grpcMsgSent.WithLabelValues(labels...),
+		})
+		done(err)
+		return err
+	}
+}
+
+// startRPC records the start of a RPC, and returns the function to record the completion.
+func (m *metrics) startRPC(labels []string) func(error) {
+	start := time.Now()
+	m.grpcStarted.WithLabelValues(labels...).Inc()
+	inFlight := m.grpcInFlight.WithLabelValues(labels...)
+	inFlight.Inc()
+	return func(err error) {
+		inFlight.Dec()
+		m.grpcDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+		m.grpcHandled.WithLabelValues(append(labels, status.Code(err).String())...).Inc()
+	}
+}
+
+// grpcLabelValues returns the label values of grpcLabels from the full method name "/package.service/method".
+func grpcLabelValues(typ, fullMethod string, p Protocol) []string {
+	service, method := "unknown", "unknown"
+	name := strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		service, method = name[:i], name[i+1:]
+	}
+	return []string{typ, service, method, string(p)}
+}
+
+// streamType returns the grpc_type label value of the streaming RPC.
+func streamType(info *grpc.StreamServerInfo) string {
+	switch {
+	case info.IsClientStream && info.IsServerStream:
+		return "bidi_stream"
+	case info.IsClientStream:
+		return "client_stream"
+	}
+	return "server_stream"
+}
+
+// monitoredStream counts the messages of the grpc.ServerStream.
+type monitoredStream struct {
+	grpc.ServerStream
+	recv prometheus.Counter
+	sent prometheus.Counter
+}
+
+func (s *monitoredStream) SendMsg(msg interface{}) error {
+	err := s.ServerStream.SendMsg(msg)
+	if err == nil {
+		s.sent.Inc()
+	}
+	return err
+}
+
+func (s *monitoredStream) RecvMsg(msg interface{}) error {
+	err := s.ServerStream.RecvMsg(msg)
+	if err == nil {
+		s.recv.Inc()
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newMetrics(t *testing.T) *metrics {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return m.(*metrics)
+}
+
+func Test_metrics_InstrumentHandler(t *testing.T) {
+	type test struct {
+		name     string
+		handler  http.HandlerFunc
+		method   string
+		wantCode string
+	}
+	tests := []test{
+		{
+			name: "Test status code defaults to 200",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			method:   http.MethodGet,
+			wantCode: "200",
+		},
+		{
+			name: "Test status code written by the handler",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+			method:   http.MethodPost,
+			wantCode: "418",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMetrics(t)
+			h := m.InstrumentHandler("Index", tt.handler)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/", nil))
+
+			if got := testutil.ToFloat64(m.httpRequests.WithLabelValues("Index", tt.method, tt.wantCode)); got != 1 {
+				t.Errorf("http_requests_total = %v, want %v", got, 1)
+			}
+			if got := testutil.ToFloat64(m.httpInFlight.WithLabelValues("Index", tt.method)); got != 0 {
+				t.Errorf("http_requests_in_flight = %v, want %v", got, 0)
+			}
+		})
+	}
+}
+
+func Test_metrics_ObserveTimeout(t *testing.T) {
+	m := newMetrics(t)
+	done := m.ObserveTimeout("Index", http.MethodGet)
+	m.ObserveTimeout("Index", http.MethodGet)
+	done()
+
+	if got := testutil.ToFloat64(m.httpTimeouts.WithLabelValues("Index", http.MethodGet)); got != 2 {
+		t.Errorf("http_request_timeouts_total = %v, want %v", got, 2)
+	}
+	if got := testutil.ToFloat64(m.httpTimedOut.WithLabelValues("Index", http.MethodGet)); got != 1 {
+		t.Errorf("http_timed_out_handlers = %v, want %v", got, 1)
+	}
+}
+
+func Test_metrics_ObservePanic(t *testing.T) {
+	m := newMetrics(t)
+	m.ObservePanic(string(ProtocolHTTP), "Index")
+
+	if got := testutil.ToFloat64(m.panics.WithLabelValues("http", "Index")); got != 1 {
+		t.Errorf("panics_recovered_total = %v, want %v", got, 1)
+	}
+}
+
+func Test_metrics_ObserveCertificateExpiry(t *testing.T) {
+	m := newMetrics(t)
+	m.ObserveCertificateExpiry("rest", "example.com", time.Unix(1700000000, 0))
+
+	if got := testutil.ToFloat64(m.certExpiry.WithLabelValues("rest", "example.com")); got != 1700000000 {
+		t.Errorf("tls_certificate_expiry_timestamp_seconds = %v, want %v", got, 1700000000)
+	}
+}
+
+func Test_metrics_UnaryServerInterceptor(t *testing.T) {
+	type test struct {
+		name     string
+		ctx      context.Context
+		err      error
+		labels   []string
+		wantCode string
+	}
+	tests := []test{
+		{
+			name:     "Test gRPC request",
+			ctx:      context.Background(),
+			labels:   []string{"unary", "helloworld.Greeter", "SayHello", "grpc"},
+			wantCode: codes.OK.String(),
+		},
+		{
+			name:     "Test gRPC-Web request with error",
+			ctx:      WithProtocol(context.Background(), ProtocolGRPCWeb),
+			err:      status.Error(codes.NotFound, "not found"),
+			labels:   []string{"unary", "helloworld.Greeter", "SayHello", "grpc-web"},
+			wantCode: codes.NotFound.String(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMetrics(t)
+			_, err := m.UnaryServerInterceptor()(tt.ctx, nil, &grpc.UnaryServerInfo{
+				FullMethod: "/helloworld.Greeter/SayHello",
+			}, func(context.Context, interface{}) (interface{}, error) {
+				return nil, tt.err
+			})
+			if err != tt.err {
+				t.Errorf("UnaryServerInterceptor() error = %v, want %v", err, tt.err)
+			}
+
+			if got := testutil.ToFloat64(m.grpcStarted.WithLabelValues(tt.labels...)); got != 1 {
+				t.Errorf("grpc_server_started_total = %v, want %v", got, 1)
+			}
+			if got := testutil.ToFloat64(m.grpcHandled.WithLabelValues(append(tt.labels, tt.wantCode)...)); got != 1 {
+				t.Errorf("grpc_server_handled_total = %v, want %v", got, 1)
+			}
+			if got := testutil.ToFloat64(m.grpcInFlight.WithLabelValues(tt.labels...)); got != 0 {
+				t.Errorf("grpc_server_in_flight = %v, want %v", got, 0)
+			}
+		})
+	}
+}
+
+type stream struct {
+	ctx context.Context
+}
+
+func (s *stream) SetHeader(metadata.MD) error  { return nil }
+func (s *stream) SendHeader(metadata.MD) error { return nil }
+func (s *stream) SetTrailer(metadata.MD)       {}
+func (s *stream) Context() context.Context     { return s.ctx }
+func (s *stream) SendMsg(interface{}) error    { return nil }
+func (s *stream) RecvMsg(interface{}) error    { return nil }
+
+func Test_metrics_StreamServerInterceptor(t *testing.T) {
+	m := newMetrics(t)
+	labels := []string{"bidi_stream", "helloworld.Greeter", "Chat", "grpc-web"}
+
+	err := m.StreamServerInterceptor()(nil, &stream{
+		ctx: WithProtocol(context.Background(), ProtocolGRPCWeb),
+	}, &grpc.StreamServerInfo{
+		FullMethod:     "/helloworld.Greeter/Chat",
+		IsClientStream: true,
+		IsServerStream: true,
+	}, func(srv interface{}, ss grpc.ServerStream) error {
+		for i := 0; i < 3; i++ {
+			ss.RecvMsg(nil)
+		}
+		return ss.SendMsg(nil)
+	})
+	if err != nil {
+		t.Errorf("StreamServerInterceptor() error = %v", err)
+	}
+
+	if got := testutil.ToFloat64(m.grpcMsgRecv.WithLabelValues(labels...)); got != 3 {
+		t.Errorf("grpc_server_msg_received_total = %v, want %v", got, 3)
+	}
+	if got := testutil.ToFloat64(m.grpcMsgSent.WithLabelValues(labels...)); got != 1 {
+		t.Errorf("grpc_server_msg_sent_total = %v, want %v", got, 1)
+	}
+	if got := testutil.ToFloat64(m.grpcHandled.WithLabelValues(append(labels, codes.OK.String())...)); got != 1 {
+		t.Errorf("grpc_server_handled_total = %v, want %v", got, 1)
+	}
+}
+
+func Test_metrics_Handler(t *testing.T) {
+	m := newMetrics(t)
+	m.ObserveTimeout("Index", http.MethodGet)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	b, err := ioutil.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"go_goroutines",
+		`http_request_timeouts_total{method="GET",route="Index"} 1`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("Handler() body does not contain %q", want)
+		}
+	}
+}
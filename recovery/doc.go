@@ -0,0 +1,2 @@
+// Package recovery recovers the panics of the REST handlers and the gRPC handlers, so that a programming error in a handler does not take down the whole process.
+package recovery
@@ -0,0 +1,99 @@
+package recovery
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/requestid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Recoverer represents the panic recovery of the REST handlers and the gRPC handlers.
+type Recoverer interface {
+	// Recover logs the recovered panic value p with the stack trace and the request ID of ctx, counts it, and returns *PanicError.
+	// It must be called in the deferred function which recovered p, so that the stack trace includes the panicking frames.
+	// In the development mode, it panics again with p instead of returning.
+	Recover(ctx context.Context, protocol metrics.Protocol, handler string, p interface{}) error
+
+	// UnaryServerInterceptor returns the grpc.UnaryServerInterceptor which recovers the panic of the unary RPC handler,
+	// and returns the codes.Internal error.
+	UnaryServerInterceptor() grpc.UnaryServerInterceptor
+
+	// StreamServerInterceptor returns the grpc.StreamServerInterceptor which recovers the panic of the streaming RPC handler,
+	// and returns the codes.Internal error.
+	StreamServerInterceptor() grpc.StreamServerInterceptor
+}
+
+// PanicError represents a panic recovered from a handler.
+type PanicError struct {
+	// Value represents the value passed to panic.
+	Value interface{}
+
+	// Stack represents the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+type recoverer struct {
+	repanic bool
+	metrics metrics.Metrics
+	log     logger.Logger
+}
+
+// New returns the Recoverer, which logs the panics by l, and counts them by m unless m is nil.
+// When repanic is true, e.g. in the development mode, the panic is logged and then propagated to crash the process loudly.
+func New(repanic bool, m metrics.Metrics, l logger.Logger) Recoverer {
+	return &recoverer{
+		repanic: repanic,
+		metrics: m,
+		log:     l,
+	}
+}
+
+// Error returns the panic value, the stack trace is not included.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (r *recoverer) Recover(ctx context.Context, protocol metrics.Protocol, handler string, p interface{}) error {
+	err := &PanicError{
+		Value: p,
+		Stack: debug.Stack(),
+	}
+	r.log.Errorf("%s handler %s panicked in request %s: %v\n%s", protocol, handler, requestid.FromContext(ctx), p, err.Stack)
+	if r.metrics != nil {
+		r.metrics.ObservePanic(string(protocol), handler)
+	}
+	if r.repanic {
+		panic(p)
+	}
+	return err
+}
+
+func (r *recoverer) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.Recover(ctx, metrics.ProtocolFromContext(ctx), info.FullMethod, p)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func (r *recoverer) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				r.Recover(ss.Context(), metrics.ProtocolFromContext(ss.Context()), info.FullMethod, p)
+				err = status.Error(codes.Internal, "internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
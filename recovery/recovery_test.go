@@ -0,0 +1,100 @@
+package recovery
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// panicMetrics records ObservePanic calls.
+type panicMetrics struct {
+	metrics.Metrics
+	panics []string
+}
+
+func (m *panicMetrics) ObservePanic(protocol, handler string) {
+	m.panics = append(m.panics, protocol+" "+handler)
+}
+
+func Test_recoverer_Recover(t *testing.T) {
+	m := new(panicMetrics)
+	r := New(false, m, logger.Nop())
+
+	var err error
+	func() {
+		defer func() {
+			err = r.Recover(context.Background(), metrics.ProtocolHTTP, "Sample Handler", recover())
+		}()
+		panic("boom")
+	}()
+
+	perr, ok := err.(*PanicError)
+	if !ok {
+		t.Fatalf("Recover() error = %v, want *PanicError", err)
+	}
+	if perr.Value != "boom" || !strings.Contains(string(perr.Stack), "Test_recoverer_Recover") {
+		t.Errorf("Recover() = %v, stack = %s", perr.Value, perr.Stack)
+	}
+	if len(m.panics) != 1 || m.panics[0] != "http Sample Handler" {
+		t.Errorf("ObservePanic() = %v", m.panics)
+	}
+}
+
+func Test_recoverer_Recover_repanic(t *testing.T) {
+	r := New(true, nil, logger.Nop())
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("Recover() panic = %v, want %v", p, "boom")
+		}
+	}()
+	func() {
+		defer func() {
+			r.Recover(context.Background(), metrics.ProtocolHTTP, "Sample Handler", recover())
+		}()
+		panic("boom")
+	}()
+	t.Error("Recover() did not panic in development mode")
+}
+
+func Test_recoverer_UnaryServerInterceptor(t *testing.T) {
+	m := new(panicMetrics)
+	ctx := metrics.WithProtocol(context.Background(), metrics.ProtocolGRPCWeb)
+	_, err := New(false, m, logger.Nop()).UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{
+		FullMethod: "/helloworld.Greeter/SayHello",
+	}, func(context.Context, interface{}) (interface{}, error) {
+		panic("boom")
+	})
+
+	if status.Code(err) != codes.Internal {
+		t.Errorf("UnaryServerInterceptor() error = %v, want %v", err, codes.Internal)
+	}
+	if len(m.panics) != 1 || m.panics[0] != "grpc-web /helloworld.Greeter/SayHello" {
+		t.Errorf("ObservePanic() = %v", m.panics)
+	}
+}
+
+type stream struct {
+	grpc.ServerStream
+}
+
+func (s *stream) Context() context.Context {
+	return context.Background()
+}
+
+func Test_recoverer_StreamServerInterceptor(t *testing.T) {
+	err := New(false, nil, logger.Nop()).StreamServerInterceptor()(nil, new(stream), &grpc.StreamServerInfo{
+		FullMethod: "/helloworld.Greeter/Chat",
+	}, func(interface{}, grpc.ServerStream) error {
+		panic("boom")
+	})
+
+	if status.Code(err) != codes.Internal {
+		t.Errorf("StreamServerInterceptor() error = %v, want %v", err, codes.Internal)
+	}
+}
@@ -0,0 +1,4 @@
+// Package requestid accepts or generates the request ID of every REST, gRPC and gRPC-Web request,
+// stores it in the request context, echoes it in the response, and forwards it on the outbound HTTP requests,
+// so that the log lines of a request can be correlated.
+package requestid
@@ -0,0 +1,181 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	// DefaultHeader represents the default header name of the request ID
+	DefaultHeader = "X-Request-ID"
+
+	// maxLength represents the maximum length of the accepted request ID, the longer ID is replaced by a new one
+	maxLength = 128
+)
+
+type idKey struct{}
+
+// New returns a new random request ID in the UUID version 4 format.
+func New() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// WithID returns a copy of ctx which carries the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+// FromContext returns the request ID in ctx, or empty string when ctx has no request ID.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(idKey{}).(string)
+	return id
+}
+
+// Middleware returns the HTTP middleware which accepts the request ID of the header, or generates a new one when it is missing or invalid.
+// The request ID is stored in the request context, and set to the response header.
+// The default header is DefaultHeader when header is empty.
+func Middleware(header string) func(http.Handler) http.Handler {
+	header = canonicalHeader(header)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(header)
+			if !valid(id) {
+				id = New()
+				r.Header.Set(header, id)
+			}
+			w.Header().Set(header, id)
+			next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+		})
+	}
+}
+
+// UnaryServerInterceptor returns the grpc.UnaryServerInterceptor which accepts the request ID of the metadata, or generates a new one.
+// The request ID is stored in the context, and sent back as the header metadata.
+// The request ID which is already stored by Middleware, e.g. of the gRPC-Web request, is used as it is.
+func UnaryServerInterceptor(header string) grpc.UnaryServerInterceptor {
+	key := strings.ToLower(canonicalHeader(header))
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, md := fromMetadata(ctx, key)
+		if md != nil {
+			// the error is ignored, the request ID is only informational
+			grpc.SetHeader(ctx, md)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the grpc.StreamServerInterceptor which accepts the request ID of the metadata, or generates a new one.
+// The request ID is stored in the context, and sent back as the header metadata.
+// The request ID which is already stored by Middleware, e.g. of the gRPC-Web request, is used as it is.
+func StreamServerInterceptor(header string) grpc.StreamServerInterceptor {
+	key := strings.ToLower(canonicalHeader(header))
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, md := fromMetadata(ss.Context(), key)
+		if md != nil {
+			ss.SetHeader(md)
+		}
+		return handler(srv, &stream{
+			ServerStream: ss,
+			ctx:          ctx,
+		})
+	}
+}
+
+// NewTransport returns the http.RoundTripper which sets the request ID of the request context to the header of the outbound request,
+// unless the header is already set. The default header is DefaultHeader when header is empty.
+func NewTransport(rt http.RoundTripper, header string) http.RoundTripper {
+	return &transport{
+		rt:     rt,
+		header: canonicalHeader(header),
+	}
+}
+
+type transport struct {
+	rt     http.RoundTripper
+	header string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := FromContext(req.Context())
+	if id == "" || req.Header.Get(t.header) != "" {
+		return t.rt.RoundTrip(req)
+	}
+	// the RoundTripper must not modify the request, so that the header is set to the copy
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set(t.header, id)
+	return t.rt.RoundTrip(r)
+}
+
+// CloseIdleConnections closes the idle connections of the underlying http.RoundTripper.
+func (t *transport) CloseIdleConnections() {
+	if c, ok := t.rt.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// fromMetadata returns the context which carries the request ID, and the metadata to send it back.
+// The metadata is nil when ctx already has the request ID.
+func fromMetadata(ctx context.Context, key string) (context.Context, metadata.MD) {
+	if FromContext(ctx) != "" {
+		return ctx, nil
+	}
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if vals := md.Get(key); len(vals) > 0 {
+			id = vals[0]
+		}
+	}
+	if !valid(id) {
+		id = New()
+	}
+	return WithID(ctx, id), metadata.Pairs(key, id)
+}
+
+// valid returns true when id is not empty, and consists of at most maxLength printable ASCII characters.
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// canonicalHeader returns the canonical header name, or DefaultHeader when header is empty.
+func canonicalHeader(header string) string {
+	if header == "" {
+		return DefaultHeader
+	}
+	return http.CanonicalHeaderKey(header)
+}
+
+// stream replaces the context of the grpc.ServerStream with the one carrying the request ID.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
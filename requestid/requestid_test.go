@@ -0,0 +1,197 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		id     string
+		want   string
+	}{
+		{
+			name: "Request ID of the header is accepted",
+			id:   "4bf92f3577b34da6",
+			want: "4bf92f3577b34da6",
+		},
+		{
+			name:   "Request ID of the configured header is accepted",
+			header: "x-correlation-id",
+			id:     "4bf92f3577b34da6",
+			want:   "4bf92f3577b34da6",
+		},
+		{
+			name: "Request ID is generated when the header is empty",
+		},
+		{
+			name: "Request ID is generated when the header is invalid",
+			id:   "has space",
+		},
+		{
+			name: "Request ID is generated when the header is too long",
+			id:   strings.Repeat("a", maxLength+1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := canonicalHeader(tt.header)
+			var got string
+			h := Middleware(tt.header)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.id != "" {
+				req.Header.Set(header, tt.id)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			switch {
+			case tt.want != "" && got != tt.want:
+				t.Errorf("FromContext() = %q, want %q", got, tt.want)
+			case tt.want == "" && (got == "" || got == tt.id):
+				t.Errorf("FromContext() = %q, want a generated ID", got)
+			}
+			if echo := rec.Header().Get(header); echo != got {
+				t.Errorf("Middleware() %s = %q, want %q", header, echo, got)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		wantSet bool
+	}{
+		{
+			name:    "Request ID of the metadata is accepted",
+			ctx:     metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "4bf92f3577b34da6")),
+			want:    "4bf92f3577b34da6",
+			wantSet: true,
+		},
+		{
+			name:    "Request ID is generated when the metadata is empty",
+			ctx:     context.Background(),
+			wantSet: true,
+		},
+		{
+			name: "Request ID of the context is used as it is",
+			ctx:  WithID(context.Background(), "4bf92f3577b34da6"),
+			want: "4bf92f3577b34da6",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			_, err := UnaryServerInterceptor("")(tt.ctx, nil, new(grpc.UnaryServerInfo), func(ctx context.Context, req interface{}) (interface{}, error) {
+				got = FromContext(ctx)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatalf("UnaryServerInterceptor() error = %v", err)
+			}
+			if got == "" || (tt.want != "" && got != tt.want) {
+				t.Errorf("FromContext() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	header metadata.MD
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	ss := &serverStream{
+		ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-correlation-id", "4bf92f3577b34da6")),
+	}
+	var got string
+	err := StreamServerInterceptor("X-Correlation-ID")(nil, ss, new(grpc.StreamServerInfo), func(srv interface{}, ss grpc.ServerStream) error {
+		got = FromContext(ss.Context())
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamServerInterceptor() error = %v", err)
+	}
+	if got != "4bf92f3577b34da6" {
+		t.Errorf("FromContext() = %q, want %q", got, "4bf92f3577b34da6")
+	}
+	if echo := ss.header.Get("x-correlation-id"); len(echo) != 1 || echo[0] != got {
+		t.Errorf("SetHeader() = %v, want %q", echo, got)
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		header string
+		want   string
+	}{
+		{
+			name: "Request ID of the context is forwarded",
+			ctx:  WithID(context.Background(), "4bf92f3577b34da6"),
+			want: "4bf92f3577b34da6",
+		},
+		{
+			name:   "Request ID of the header is kept",
+			ctx:    WithID(context.Background(), "4bf92f3577b34da6"),
+			header: "00f067aa0ba902b7",
+			want:   "00f067aa0ba902b7",
+		},
+		{
+			name: "Header is not set without the request ID",
+			ctx:  context.Background(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			rt := NewTransport(roundTripper(func(req *http.Request) (*http.Response, error) {
+				got = req.Header.Get(DefaultHeader)
+				return nil, nil
+			}), "")
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil).WithContext(tt.ctx)
+			if tt.header != "" {
+				req.Header.Set(DefaultHeader, tt.header)
+			}
+			rt.RoundTrip(req)
+
+			if got != tt.want {
+				t.Errorf("RoundTrip() %s = %q, want %q", DefaultHeader, got, tt.want)
+			}
+			if tt.header == "" && req.Header.Get(DefaultHeader) != "" {
+				t.Error("RoundTrip() modified the original request")
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/kpango/golang-server-template/handler/rest"
+	"github.com/kpango/golang-server-template/requestid"
+)
+
+const (
+	// codeTimeout represents the machine-readable code of the handler timeout
+	codeTimeout = "timeout"
+
+	// codeNotFound represents the machine-readable code of the unknown path
+	codeNotFound = "not_found"
+
+	// codeMethodNotAllowed represents the machine-readable code of the unregistered method
+	codeMethodNotAllowed = "method_not_allowed"
+)
+
+// writeError responds the RFC 7807 problem details of err, and logs err with the request ID.
+// The internal error text is hidden from the client unless the server is in the development mode.
+func (rt *router) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	id := requestid.FromContext(r.Context())
+	p := rest.NewProblem(r, err, id, rt.expose)
+	if p.Status >= http.StatusInternalServerError {
+		rt.log.Errorf("request %s %s %s failed: %v", id, r.Method, r.URL.Path, err)
+	} else {
+		rt.log.Debugf("request %s %s %s failed: %v", id, r.Method, r.URL.Path, err)
+	}
+
+	err = rest.WriteProblem(w, p)
+	if err != nil {
+		rt.log.Errorf("request %s: failed to write the problem details: %v", id, err)
+	}
+}
@@ -0,0 +1,20 @@
+package router
+
+import "net/http"
+
+// Middleware represents a cross-cutting behavior of the routes, such as logging, authentication, recovery, rate limiting and compression.
+// It returns the http.Handler which runs its own logic around the next handler.
+type Middleware func(next http.Handler) http.Handler
+
+// Chain returns the Middleware which applies the middlewares in order, the first one is the outermost,
+// so that it runs first on the request and last on the response.
+func Chain(mws ...Middleware) Middleware {
+	return func(next http.Handler) http.Handler {
+		for i := len(mws) - 1; i >= 0; i-- {
+			if mws[i] != nil {
+				next = mws[i](next)
+			}
+		}
+		return next
+	}
+}
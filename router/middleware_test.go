@@ -0,0 +1,89 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/logger"
+)
+
+// recorder returns the Middleware which appends name to got before and after the next handler.
+func recorder(got *[]string, name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*got = append(*got, name+":before")
+			next.ServeHTTP(w, r)
+			*got = append(*got, name+":after")
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name string
+		mws  func(*[]string) []Middleware
+		want []string
+	}{
+		{
+			name: "Middlewares are applied in order",
+			mws: func(got *[]string) []Middleware {
+				return []Middleware{recorder(got, "first"), recorder(got, "second")}
+			},
+			want: []string{"first:before", "second:before", "handler", "second:after", "first:after"},
+		},
+		{
+			name: "Nil middleware is skipped",
+			mws: func(got *[]string) []Middleware {
+				return []Middleware{nil, recorder(got, "first")}
+			},
+			want: []string{"first:before", "handler", "first:after"},
+		},
+		{
+			name: "Empty chain returns the handler",
+			mws: func(got *[]string) []Middleware {
+				return nil
+			},
+			want: []string{"handler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			h := Chain(tt.mws(&got)...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = append(got, "handler")
+			}))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chain() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_router_handler(t *testing.T) {
+	var got []string
+	rt := &router{
+		log:     logger.Nop(),
+		timeout: int64(time.Second),
+	}
+	h := rt.handler(Route{
+		Name:    "Sample Handler",
+		Methods: []string{http.MethodGet},
+		Pattern: "/sample",
+		HandlerFunc: func(w http.ResponseWriter, r *http.Request) error {
+			got = append(got, "handler")
+			return nil
+		},
+		Middlewares: []Middleware{recorder(&got, "route")},
+	}, []Middleware{recorder(&got, "global")})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sample", nil))
+
+	want := []string{"global:before", "route:before", "handler", "route:after", "global:after"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("handler() = %v, want %v", got, want)
+	}
+}
@@ -7,78 +7,264 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync/atomic"
 	"time"
 
-	"github.com/kpango/glg"
+	"github.com/kpango/golang-server-template/accesslog"
 	"github.com/kpango/golang-server-template/config"
 	"github.com/kpango/golang-server-template/handler/rest"
+	"github.com/kpango/golang-server-template/identity"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/recovery"
+	"github.com/kpango/golang-server-template/requestid"
+	"github.com/kpango/golang-server-template/tracing"
 )
 
-//New returns Routed ServeMux
-func New(cfg config.Server, h rest.Handler) *http.ServeMux {
+const (
+	// defaultTimeout represents the handler timeout when config.Server.Timeout is empty
+	defaultTimeout = time.Second * 3
+)
+
+// Router represents the routed http.Handler, which the configuration can be reloaded without restart.
+type Router interface {
+	http.Handler
+
+	// Reload applies the handler timeout from the given configuration.
+	Reload(cfg config.Server) error
+}
+
+type router struct {
+	tree *node
+
+	// root accepts or generates the request ID, stores the verified client identity, and then dispatches the request.
+	root http.Handler
+
+	// metrics records the requests of each route, it is nil when the metrics are disabled.
+	metrics metrics.Metrics
 
-	http.DefaultTransport.(*http.Transport).MaxIdleConnsPerHost = 32
+	// tracer starts the span of each route, it is nil when the tracing is disabled.
+	tracer tracing.Tracer
 
-	mux := http.NewServeMux()
+	// accessLog logs the requests of each route and the unrouted requests, it is nil when the access log is disabled.
+	accessLog accesslog.Logger
+
+	// notFound and notAllowed respond the request of the unknown path and the unregistered method.
+	notFound   http.Handler
+	notAllowed http.Handler
+
+	// recoverer recovers the panic of rest.Func.
+	recoverer recovery.Recoverer
+
+	// log logs the failed requests and the handlers running after the timeout.
+	log logger.Logger
+
+	// expose represents the internal error text is shown to the client, it is true in the development mode.
+	expose bool
+
+	// timeout represents the handler timeout in nanoseconds, it is accessed atomically.
+	timeout int64
+
+	// timedOut represents the number of the handlers running after the timeout, it is accessed atomically.
+	timedOut int64
+}
 
+// New returns the Router, which routes the requests to the handler defined in NewRoutes.
+// The Route.Pattern may contain the named parameters "{name}" and the wildcard "{name...}", see node for the syntax,
+// and the Routes of the same pattern can be registered for the different methods.
+// The request of the matched pattern but of the unregistered method is responded HTTP Status Method Not Allowed (405) with the Allow header.
+// It panics when the Routes have an invalid or conflicting pattern.
+//
+// The panic of rest.Func is recovered and responded HTTP Status Internal Server Error (500), except for the development mode, which panics again.
+// The requests of each route are recorded by m labelled by Route.Name, unless m is nil.
+// The server span named Route.Name is started by tr for each request, and it is carried by the request context passed to rest.Func, unless tr is nil.
+// Every request, including the unknown path and method, is logged by al labelled by Route.Name, unless al is nil.
+// The failed requests, the recovered panics and the handlers running after the timeout are logged by l.
+//
+// The request ID of the cfg.RequestIDHeader is accepted or generated for every request, including the unknown path and method,
+// and it is stored in the request context and echoed as the response header.
+// The client identity verified by the mutual TLS is stored in the request context, which is read by identity.FromContext.
+//
+// The mws are applied to all routes. A request is dispatched to the Route by the path and method, and then passes through the layers in the following order:
+// the access log, the tracing, the metrics, the global mws in order, the Route.Middlewares in order, the handler timeout, and then the rest.Func.
+func New(cfg config.Server, h rest.Handler, m metrics.Metrics, tr tracing.Tracer, al accesslog.Logger, l logger.Logger, mws ...Middleware) Router {
 	dur, err := time.ParseDuration(cfg.Timeout)
 	if err != nil {
-		dur = time.Second * 3
+		dur = defaultTimeout
 	}
 
+	rt := &router{
+		tree:      new(node),
+		metrics:   m,
+		tracer:    tr,
+		accessLog: al,
+		recoverer: recovery.New(cfg.Mode == config.ModeDevelopment, m, l),
+		log:       l,
+		expose:    cfg.Mode == config.ModeDevelopment,
+		timeout:   int64(dur),
+	}
+	rt.root = Chain(requestid.Middleware(cfg.RequestIDHeader), identity.Middleware())(http.HandlerFunc(rt.dispatch))
+	rt.notFound = rt.logAccess("", http.HandlerFunc(rt.writeNotFound))
+	rt.notAllowed = rt.logAccess("", http.HandlerFunc(rt.writeNotAllowed))
+
 	for _, route := range NewRoutes(h) {
-		//関数名取得
-		mux.Handle(route.Pattern, routing(route.Methods, dur, route.HandlerFunc))
+		rt.register(route, mws)
+	}
+
+	return rt
+}
+
+// register adds the handler of the route for each of Route.Methods.
+func (rt *router) register(route Route, mws []Middleware) {
+	handler := rt.handler(route, mws)
+	if rt.metrics != nil {
+		handler = rt.metrics.InstrumentHandler(route.Name, handler)
+	}
+	if rt.tracer != nil {
+		handler = rt.tracer.InstrumentHandler(route.Name, handler)
+	}
+	handler = rt.logAccess(route.Name, handler)
+	for _, method := range route.Methods {
+		rt.tree.add(route.Pattern, strings.ToUpper(method), handler)
+	}
+}
+
+// handler returns the http.Handler of the route, which the global mws and the route middlewares are applied to.
+func (rt *router) handler(route Route, mws []Middleware) http.Handler {
+	//関数名取得
+	h := rt.routing(route.Name, route.HandlerFunc)
+	return Chain(mws...)(Chain(route.Middlewares...)(h))
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.root.ServeHTTP(w, r)
+}
+
+// dispatch dispatches the request to the handler registered for the request path and method.
+// The path parameters are stored to the request context, which are read by rest.Params.
+func (rt *router) dispatch(w http.ResponseWriter, r *http.Request) {
+	params := make(map[string]string)
+	var e *endpoint
+	segs, err := segments(r.URL.EscapedPath())
+	if err == nil {
+		e = rt.tree.match(segs, params)
+	}
+	if e == nil {
+		rt.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	h := e.handler(r.Method)
+	if h == nil {
+		w.Header().Set("Allow", e.allow())
+		rt.notAllowed.ServeHTTP(w, r)
+		return
+	}
+
+	if len(params) > 0 {
+		r = r.WithContext(rest.WithParams(r.Context(), params))
+	}
+	h.ServeHTTP(w, r)
+}
+
+// logAccess returns h which requests are logged labelled by the route name, or h itself when the access log is disabled.
+func (rt *router) logAccess(route string, h http.Handler) http.Handler {
+	if rt.accessLog == nil {
+		return h
+	}
+	return rt.accessLog.InstrumentHandler(route, h)
+}
+
+// writeNotFound responds HTTP Status Not Found (404) for the unknown path.
+func (rt *router) writeNotFound(w http.ResponseWriter, r *http.Request) {
+	rt.writeError(w, r, rest.NewError(http.StatusNotFound, codeNotFound,
+		fmt.Sprintf("path %s is not found", r.URL.Path)))
+}
+
+// writeNotAllowed discards the request body, and responds HTTP Status Method Not Allowed (405) for the unregistered method.
+// The Allow header must be set by the caller.
+func (rt *router) writeNotAllowed(w http.ResponseWriter, r *http.Request) {
+	_, err := io.Copy(ioutil.Discard, r.Body)
+	if err != nil {
+		rt.log.Errorf("request %s: failed to discard the request body: %v", requestid.FromContext(r.Context()), err)
+	}
+	err = r.Body.Close()
+	if err != nil {
+		rt.log.Errorf("request %s: failed to close the request body: %v", requestid.FromContext(r.Context()), err)
 	}
+	rt.writeError(w, r, rest.NewError(http.StatusMethodNotAllowed, codeMethodNotAllowed,
+		fmt.Sprintf("method %s is not allowed", r.Method)))
+}
 
-	return mux
+// Reload updates the handler timeout, it returns error and keeps the current timeout when cfg.Timeout is not a valid duration.
+func (rt *router) Reload(cfg config.Server) error {
+	if cfg.Timeout == "" {
+		atomic.StoreInt64(&rt.timeout, int64(defaultTimeout))
+		return nil
+	}
+	dur, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&rt.timeout, int64(dur))
+	return nil
 }
 
-func routing(m []string, t time.Duration, h rest.Func) http.Handler {
+// routing returns the http.Handler which runs h with the handler timeout.
+// The response of h is buffered, and it is written when h returns before the timeout,
+// otherwise HTTP Status Gateway Timeout (504) is responded, and the later writes of h are discarded.
+// When the client cancels the request before the timeout, the response is discarded without counting the timeout.
+// The handler which keeps running after the timeout is counted until it returns, so that the leaked goroutines are observable.
+func (rt *router) routing(name string, h rest.Func) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		for _, method := range m {
-			if strings.EqualFold(r.Method, method) || method == "*" {
-
-				ctx, cancel := context.WithTimeout(r.Context(), t)
-				defer cancel()
-				start := time.Now()
-				ech := make(chan error)
-				go func() {
-					ech <- h(w, r.WithContext(ctx))
-				}()
-
-				for {
-					select {
-					case err := <-ech:
-						if err != nil {
-							http.Error(w,
-								fmt.Sprintf("Error: %s\t%s",
-									err.Error(),
-									http.StatusText(http.StatusInternalServerError)),
-								http.StatusInternalServerError)
-							glg.Error(err)
-						}
-						return
-					case <-ctx.Done():
-						glg.Errorf("Handler Time Out: %v", time.Since(start))
-						return
-					}
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(atomic.LoadInt64(&rt.timeout)))
+		defer cancel()
+		start := time.Now()
+		tw := newTimeoutWriter()
+		// the channel is buffered, so that the goroutine does not block after the timeout
+		ech := make(chan error, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					ech <- rt.recoverer.Recover(ctx, metrics.ProtocolHTTP, name, p)
 				}
+			}()
+			ech <- h(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case err := <-ech:
+			if err != nil {
+				// the partial response written before the error is discarded
+				tw.reset()
+				rt.writeError(tw, r, err)
 			}
-		}
+			err = tw.flush(w)
+			if err != nil {
+				rt.log.Errorf("request %s: failed to write the response: %v", requestid.FromContext(r.Context()), err)
+			}
+		case <-ctx.Done():
+			tw.timeout()
+			// the request context is canceled before the deadline when the client disconnects, which is not the timeout of the server
+			if deadline, ok := ctx.Deadline(); ok && time.Now().Before(deadline) {
+				rt.log.Debugf("request %s: client canceled the request of handler %s: %v", requestid.FromContext(r.Context()), name, ctx.Err())
+				return
+			}
+			rt.writeError(w, r, rest.NewError(http.StatusGatewayTimeout, codeTimeout,
+				fmt.Sprintf("handler timed out after %v", time.Since(start).Round(time.Millisecond))))
 
-		_, err := io.Copy(ioutil.Discard, r.Body)
-		if err != nil {
-			glg.Fatalln(err)
-		}
-		err = r.Body.Close()
-		if err != nil {
-			glg.Fatalln(err)
+			running := atomic.AddInt64(&rt.timedOut, 1)
+			rt.log.Warnf("request %s: handler %s is still running after the timeout, %d handlers are running after the timeout",
+				requestid.FromContext(r.Context()), name, running)
+			done := func() {}
+			if rt.metrics != nil {
+				done = rt.metrics.ObserveTimeout(name, r.Method)
+			}
+			go func() {
+				<-ech
+				atomic.AddInt64(&rt.timedOut, -1)
+				done()
+			}()
 		}
-		http.Error(w,
-			fmt.Sprintf("Method: %s\t%s",
-				r.Method,
-				http.StatusText(http.StatusMethodNotAllowed)),
-			http.StatusMethodNotAllowed)
 	})
 }
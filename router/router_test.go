@@ -0,0 +1,135 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/handler/rest"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/requestid"
+)
+
+func Test_router_ServeHTTP(t *testing.T) {
+	rt := &router{
+		log:     logger.Nop(),
+		tree:    new(node),
+		timeout: int64(time.Second),
+	}
+	rt.root = requestid.Middleware("")(http.HandlerFunc(rt.dispatch))
+	rt.notFound = http.HandlerFunc(rt.writeNotFound)
+	rt.notAllowed = http.HandlerFunc(rt.writeNotAllowed)
+	respond := func(body string) rest.Func {
+		return func(w http.ResponseWriter, r *http.Request) error {
+			_, err := io.WriteString(w, body+rest.Param(r, "id"))
+			return err
+		}
+	}
+	for _, route := range []Route{
+		{
+			Name:        "Get User",
+			Methods:     []string{http.MethodGet},
+			Pattern:     "/users/{id}",
+			HandlerFunc: respond("get "),
+		},
+		{
+			Name:        "Update User",
+			Methods:     []string{http.MethodPut, "patch"},
+			Pattern:     "/users/{id}",
+			HandlerFunc: respond("update "),
+		},
+		{
+			Name:        "Any",
+			Methods:     []string{anyMethod},
+			Pattern:     "/any",
+			HandlerFunc: respond("any"),
+		},
+	} {
+		rt.register(route, nil)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		wantCode  int
+		wantBody  string
+		wantAllow string
+		requestID string
+	}{
+		{
+			name:     "GET handler with parameter",
+			method:   http.MethodGet,
+			path:     "/users/42",
+			wantCode: http.StatusOK,
+			wantBody: "get 42",
+		},
+		{
+			name:      "Request ID of the header is echoed",
+			method:    http.MethodGet,
+			path:      "/users/42",
+			wantCode:  http.StatusOK,
+			wantBody:  "get 42",
+			requestID: "4bf92f3577b34da6",
+		},
+		{
+			name:     "PATCH handler of the same pattern",
+			method:   http.MethodPatch,
+			path:     "/users/42",
+			wantCode: http.StatusOK,
+			wantBody: "update 42",
+		},
+		{
+			name:     "HEAD is served by GET handler",
+			method:   http.MethodHead,
+			path:     "/users/42",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:      "Unregistered method is not allowed",
+			method:    http.MethodDelete,
+			path:      "/users/42",
+			wantCode:  http.StatusMethodNotAllowed,
+			wantAllow: "GET, HEAD, PATCH, PUT",
+		},
+		{
+			name:     "Any method",
+			method:   http.MethodDelete,
+			path:     "/any",
+			wantCode: http.StatusOK,
+			wantBody: "any",
+		},
+		{
+			name:     "Unknown path is not found",
+			method:   http.MethodGet,
+			path:     "/users",
+			wantCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set(requestid.DefaultHeader, tt.requestID)
+			}
+			rt.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("ServeHTTP() code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("ServeHTTP() body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("ServeHTTP() Allow = %q, want %q", got, tt.wantAllow)
+			}
+			got := rec.Header().Get(requestid.DefaultHeader)
+			if got == "" || (tt.requestID != "" && got != tt.requestID) {
+				t.Errorf("ServeHTTP() %s = %q, want %q", requestid.DefaultHeader, got, tt.requestID)
+			}
+		})
+	}
+}
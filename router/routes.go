@@ -7,10 +7,18 @@ import (
 )
 
 type Route struct {
-	Name        string
-	Methods     []string
+	Name string
+
+	// Methods represents the HTTP methods served by the route, "*" accepts every method.
+	Methods []string
+
+	// Pattern represents the request path of the route, e.g. "/users/{id}" or "/static/{path...}".
+	// The path parameters are read by rest.Param in the HandlerFunc.
 	Pattern     string
 	HandlerFunc rest.Func
+
+	// Middlewares represents the middlewares applied only to the route, inside the global middlewares given to New.
+	Middlewares []Middleware
 }
 
 func NewRoutes(h rest.Handler) []Route {
@@ -22,6 +30,7 @@ func NewRoutes(h rest.Handler) []Route {
 			},
 			"/sample",
 			h.Sample,
+			nil,
 		},
 	}
 }
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+)
+
+// timeoutWriter buffers the response of the handler, so that the response is written to the client only when the handler returns before the timeout.
+// After the timeout, the writes of the handler are discarded, and Write returns http.ErrHandlerTimeout.
+// It does not implement http.Flusher, since the buffered response cannot be flushed before the handler returns.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	h           http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func newTimeoutWriter() *timeoutWriter {
+	return &timeoutWriter{
+		h:    make(http.Header),
+		code: http.StatusOK,
+	}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	tw.wroteHeader = true
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.code = code
+	tw.wroteHeader = true
+}
+
+// reset discards the buffered response. It must be called after the handler returns.
+func (tw *timeoutWriter) reset() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.h = make(http.Header)
+	tw.buf.Reset()
+	tw.code = http.StatusOK
+	tw.wroteHeader = false
+}
+
+// flush writes the buffered response to w. It must be called after the handler returns.
+// The invalid status code written by the handler is responded as the internal server error.
+func (tw *timeoutWriter) flush(w http.ResponseWriter) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	dst := w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	code := tw.code
+	if code < 100 || code > 599 {
+		// the invalid status code makes http.ResponseWriter.WriteHeader panic
+		code = http.StatusInternalServerError
+	}
+	w.WriteHeader(code)
+	_, err := w.Write(tw.buf.Bytes())
+	return err
+}
+
+// timeout marks the handler timed out, so that the later writes of the handler are discarded.
+// The header map of tw must not be read after that, since the handler may still be modifying it.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+}
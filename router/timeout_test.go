@@ -0,0 +1,190 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/handler/rest"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/recovery"
+	"github.com/kpango/golang-server-template/requestid"
+	"github.com/pkg/errors"
+)
+
+func Test_router_routing(t *testing.T) {
+	type test struct {
+		name       string
+		handler    func(release <-chan struct{}, written chan<- error) func(http.ResponseWriter, *http.Request) error
+		wantCode   int
+		wantBody   string
+		wantHeader string
+		wantJSON   bool
+	}
+	tests := []test{
+		{
+			name: "Response is written when the handler returns before the timeout",
+			handler: func(<-chan struct{}, chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					w.Header().Set("X-Test", "test")
+					w.WriteHeader(http.StatusCreated)
+					_, err := io.WriteString(w, "created")
+					return err
+				}
+			},
+			wantCode:   http.StatusCreated,
+			wantBody:   "created",
+			wantHeader: "test",
+		},
+		{
+			name: "Handler error is responded as internal server error",
+			handler: func(<-chan struct{}, chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					return errors.New("boom")
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+			wantJSON: true,
+		},
+		{
+			name: "Handler error without the status code is responded as internal server error",
+			handler: func(<-chan struct{}, chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					return &rest.Error{Code: "broken"}
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+			wantJSON: true,
+		},
+		{
+			name: "Invalid status code of the handler is responded as internal server error",
+			handler: func(<-chan struct{}, chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					w.WriteHeader(0)
+					return nil
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+		},
+		{
+			name: "Panic is responded as internal server error and the partial response is discarded",
+			handler: func(<-chan struct{}, chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					w.Header().Set("X-Test", "test")
+					io.WriteString(w, "partial")
+					panic("boom")
+				}
+			},
+			wantCode: http.StatusInternalServerError,
+			wantJSON: true,
+		},
+		{
+			name: "Gateway timeout is responded and the later writes are discarded",
+			handler: func(release <-chan struct{}, written chan<- error) func(http.ResponseWriter, *http.Request) error {
+				return func(w http.ResponseWriter, r *http.Request) error {
+					<-r.Context().Done()
+					<-release
+					w.Header().Set("X-Test", "test")
+					_, err := io.WriteString(w, "too late")
+					written <- err
+					return err
+				}
+			},
+			wantCode: http.StatusGatewayTimeout,
+			wantJSON: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := &router{
+				recoverer: recovery.New(false, nil, logger.Nop()),
+				log:       logger.Nop(),
+				timeout:   int64(50 * time.Millisecond),
+			}
+			release := make(chan struct{})
+			written := make(chan error, 1)
+
+			rec := httptest.NewRecorder()
+			requestid.Middleware("")(rt.routing("test", tt.handler(release, written))).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("routing() code = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("routing() body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := rec.Header().Get("X-Test"); got != tt.wantHeader {
+				t.Errorf("routing() X-Test = %q, want %q", got, tt.wantHeader)
+			}
+			if !tt.wantJSON {
+				return
+			}
+
+			var body rest.Problem
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Status != tt.wantCode || body.RequestID == "" || body.RequestID != rec.Header().Get(requestid.DefaultHeader) {
+				t.Errorf("routing() body = %s, error = %v", rec.Body.String(), err)
+			}
+			if got := rec.Header().Get("Content-Type"); got != rest.ProblemJSON {
+				t.Errorf("routing() Content-Type = %q, want %q", got, rest.ProblemJSON)
+			}
+			if strings.Contains(rec.Body.String(), "boom") {
+				t.Errorf("routing() body = %s, the panic value is exposed", rec.Body.String())
+			}
+			if tt.wantCode != http.StatusGatewayTimeout {
+				return
+			}
+			if got := atomic.LoadInt64(&rt.timedOut); got != 1 {
+				t.Errorf("routing() timed out handlers = %d, want %d", got, 1)
+			}
+
+			close(release)
+			if err := <-written; err != http.ErrHandlerTimeout {
+				t.Errorf("Write() after timeout error = %v, want %v", err, http.ErrHandlerTimeout)
+			}
+			for i := 0; i < 100 && atomic.LoadInt64(&rt.timedOut) != 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if got := atomic.LoadInt64(&rt.timedOut); got != 0 {
+				t.Errorf("routing() timed out handlers after return = %d, want %d", got, 0)
+			}
+		})
+	}
+}
+
+func Test_router_routing_canceled(t *testing.T) {
+	rt := &router{
+		recoverer: recovery.New(false, nil, logger.Nop()),
+		log:       logger.Nop(),
+		timeout:   int64(time.Second),
+	}
+	returned := make(chan struct{})
+	h := rt.routing("test", func(w http.ResponseWriter, r *http.Request) error {
+		defer close(returned)
+		<-r.Context().Done()
+		_, err := io.WriteString(w, "canceled")
+		return err
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("routing() returned after %v, want before the timeout", elapsed)
+	}
+	if rec.Body.Len() != 0 || rec.Code == http.StatusGatewayTimeout {
+		t.Errorf("routing() code = %d, body = %q, want no response", rec.Code, rec.Body.String())
+	}
+	if got := atomic.LoadInt64(&rt.timedOut); got != 0 {
+		t.Errorf("routing() timed out handlers = %d, want %d", got, 0)
+	}
+	<-returned
+}
@@ -0,0 +1,191 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// anyMethod represents the Route.Methods value which accepts every HTTP method
+	anyMethod = "*"
+
+	// wildcardSuffix represents the suffix of the parameter name which matches the rest of the path
+	wildcardSuffix = "..."
+)
+
+// node represents a path segment of the registered route patterns.
+// The pattern segment is a static string, a named parameter "{name}" which matches a non-empty segment,
+// or a wildcard "{name...}" which matches the rest of the path, and must be the last segment.
+type node struct {
+	static map[string]*node
+
+	param     *node
+	paramName string
+
+	wildcard     *endpoint
+	wildcardName string
+
+	endpoint *endpoint
+}
+
+// endpoint represents the handlers of a route pattern, keyed by the HTTP method.
+type endpoint struct {
+	pattern  string
+	handlers map[string]http.Handler
+}
+
+// add registers h for the method of the pattern.
+// It panics when the pattern is invalid, or conflicts with the registered patterns, as http.ServeMux does.
+func (n *node) add(pattern, method string, h http.Handler) {
+	if !strings.HasPrefix(pattern, "/") {
+		panic(fmt.Sprintf("router: invalid pattern %q, must start with \"/\"", pattern))
+	}
+
+	segs := strings.Split(pattern[1:], "/")
+	names := make(map[string]bool, len(segs))
+	var e *endpoint
+	for i, seg := range segs {
+		name, ok := paramName(seg)
+		if !ok {
+			if n.static == nil {
+				n.static = make(map[string]*node)
+			}
+			if n.static[seg] == nil {
+				n.static[seg] = new(node)
+			}
+			n = n.static[seg]
+			continue
+		}
+
+		if name == "" || names[strings.TrimSuffix(name, wildcardSuffix)] {
+			panic(fmt.Sprintf("router: invalid parameter %q in pattern %q", seg, pattern))
+		}
+		names[strings.TrimSuffix(name, wildcardSuffix)] = true
+
+		if strings.HasSuffix(name, wildcardSuffix) {
+			name = strings.TrimSuffix(name, wildcardSuffix)
+			if i != len(segs)-1 || name == "" {
+				panic(fmt.Sprintf("router: invalid wildcard %q in pattern %q, must be the last segment", seg, pattern))
+			}
+			if n.wildcard != nil && n.wildcardName != name {
+				panic(fmt.Sprintf("router: wildcard %q in pattern %q conflicts with %q", seg, pattern, n.wildcard.pattern))
+			}
+			if n.wildcard == nil {
+				n.wildcard = &endpoint{pattern: pattern}
+				n.wildcardName = name
+			}
+			e = n.wildcard
+			break
+		}
+
+		if n.param != nil && n.paramName != name {
+			panic(fmt.Sprintf("router: parameter %q in pattern %q conflicts with {%s}", seg, pattern, n.paramName))
+		}
+		if n.param == nil {
+			n.param = new(node)
+			n.paramName = name
+		}
+		n = n.param
+	}
+
+	if e == nil {
+		if n.endpoint == nil {
+			n.endpoint = &endpoint{pattern: pattern}
+		}
+		e = n.endpoint
+	}
+	if e.handlers == nil {
+		e.handlers = make(map[string]http.Handler)
+	}
+	if _, ok := e.handlers[method]; ok {
+		panic(fmt.Sprintf("router: multiple registrations for %s %s", method, pattern))
+	}
+	e.handlers[method] = h
+}
+
+// match returns the endpoint of the path, and stores the path parameters to params.
+// The static segment has priority over the parameter, and the parameter has priority over the wildcard.
+// The wildcard does not match the rest of the path which has the ".." segment or the escaped "/", so that the handler can use its value as a relative file path.
+func (n *node) match(segs []string, params map[string]string) *endpoint {
+	if len(segs) == 0 {
+		return n.endpoint
+	}
+
+	seg := segs[0]
+	if c, ok := n.static[seg]; ok {
+		if e := c.match(segs[1:], params); e != nil {
+			return e
+		}
+	}
+	if n.param != nil && seg != "" {
+		if e := n.param.match(segs[1:], params); e != nil {
+			params[n.paramName] = seg
+			return e
+		}
+	}
+	if n.wildcard != nil && !traversal(segs) {
+		params[n.wildcardName] = strings.Join(segs, "/")
+		return n.wildcard
+	}
+	return nil
+}
+
+// handler returns the handler of the method. The GET handler also serves HEAD when HEAD is not registered.
+func (e *endpoint) handler(method string) http.Handler {
+	if h, ok := e.handlers[method]; ok {
+		return h
+	}
+	if h, ok := e.handlers[http.MethodGet]; ok && method == http.MethodHead {
+		return h
+	}
+	return e.handlers[anyMethod]
+}
+
+// allow returns the Allow header value, which lists the registered methods in order.
+func (e *endpoint) allow() string {
+	methods := make([]string, 0, len(e.handlers)+1)
+	for method := range e.handlers {
+		methods = append(methods, method)
+	}
+	if _, ok := e.handlers[http.MethodGet]; ok {
+		if _, ok := e.handlers[http.MethodHead]; !ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+// paramName returns the parameter name of the pattern segment "{name}" or "{name...}", and true when seg is a parameter.
+func paramName(seg string) (string, bool) {
+	if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+		return "", false
+	}
+	return seg[1 : len(seg)-1], true
+}
+
+// traversal returns true when any of the unescaped segments is ".." or has "/", which is escaped as "%2F" in the request path.
+func traversal(segs []string) bool {
+	for _, seg := range segs {
+		if seg == ".." || strings.Contains(seg, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// segments returns the unescaped segments of the escaped request path.
+func segments(path string) ([]string, error) {
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, seg := range segs {
+		s, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, err
+		}
+		segs[i] = s
+	}
+	return segs, nil
+}
@@ -0,0 +1,189 @@
+package router
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func Test_node_match(t *testing.T) {
+	n := new(node)
+	for _, pattern := range []string{
+		"/",
+		"/users",
+		"/users/",
+		"/users/me",
+		"/users/{id}",
+		"/users/{id}/posts/{post}",
+		"/static/{path...}",
+		"/files/{name}/{rest...}",
+	} {
+		n.add(pattern, http.MethodGet, http.NotFoundHandler())
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		wantPattern string
+		wantParams  map[string]string
+	}{
+		{
+			name:        "Match root",
+			path:        "/",
+			wantPattern: "/",
+		},
+		{
+			name:        "Match static path",
+			path:        "/users",
+			wantPattern: "/users",
+		},
+		{
+			name:        "Trailing slash is a different path",
+			path:        "/users/",
+			wantPattern: "/users/",
+		},
+		{
+			name:        "Static segment has priority over parameter",
+			path:        "/users/me",
+			wantPattern: "/users/me",
+		},
+		{
+			name:        "Match parameter",
+			path:        "/users/42",
+			wantPattern: "/users/{id}",
+			wantParams:  map[string]string{"id": "42"},
+		},
+		{
+			name:        "Match multiple parameters",
+			path:        "/users/42/posts/7",
+			wantPattern: "/users/{id}/posts/{post}",
+			wantParams:  map[string]string{"id": "42", "post": "7"},
+		},
+		{
+			name:        "Parameter is unescaped",
+			path:        "/users/a%2Fb",
+			wantPattern: "/users/{id}",
+			wantParams:  map[string]string{"id": "a/b"},
+		},
+		{
+			name:        "Match wildcard",
+			path:        "/static/css/main.css",
+			wantPattern: "/static/{path...}",
+			wantParams:  map[string]string{"path": "css/main.css"},
+		},
+		{
+			name:        "Match empty wildcard",
+			path:        "/static/",
+			wantPattern: "/static/{path...}",
+			wantParams:  map[string]string{"path": ""},
+		},
+		{
+			name:        "Match parameter and wildcard",
+			path:        "/files/a/b/c",
+			wantPattern: "/files/{name}/{rest...}",
+			wantParams:  map[string]string{"name": "a", "rest": "b/c"},
+		},
+		{
+			name: "Wildcard does not match the escaped parent directories",
+			path: "/static/..%2F..%2Fetc",
+		},
+		{
+			name: "Wildcard does not match the parent directory",
+			path: "/static/css/../../etc",
+		},
+		{
+			name: "Wildcard does not match the escaped slash",
+			path: "/files/a/b%2Fc",
+		},
+		{
+			name: "Wildcard does not match the parent path",
+			path: "/static",
+		},
+		{
+			name: "Parameter does not match empty segment",
+			path: "/users//posts/7",
+		},
+		{
+			name: "Unknown path does not match",
+			path: "/unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segs, err := segments(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params := make(map[string]string)
+			e := n.match(segs, params)
+			if tt.wantPattern == "" {
+				if e != nil {
+					t.Errorf("match() = %s, want nil", e.pattern)
+				}
+				return
+			}
+			if e == nil || e.pattern != tt.wantPattern {
+				t.Errorf("match() = %v, want %s", e, tt.wantPattern)
+				return
+			}
+			if tt.wantParams == nil {
+				tt.wantParams = map[string]string{}
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("match() params = %v, want %v", params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func Test_node_add(t *testing.T) {
+	tests := []struct {
+		name      string
+		patterns  []string
+		wantPanic bool
+	}{
+		{
+			name:     "Same pattern for different methods",
+			patterns: []string{"/users/{id}", "/users/{id}"},
+		},
+		{
+			name:      "Pattern must start with slash",
+			patterns:  []string{"users"},
+			wantPanic: true,
+		},
+		{
+			name:      "Conflicting parameter names",
+			patterns:  []string{"/users/{id}", "/users/{name}/posts"},
+			wantPanic: true,
+		},
+		{
+			name:      "Duplicated parameter names",
+			patterns:  []string{"/users/{id}/posts/{id}"},
+			wantPanic: true,
+		},
+		{
+			name:      "Wildcard must be the last segment",
+			patterns:  []string{"/static/{path...}/index.html"},
+			wantPanic: true,
+		},
+		{
+			name:      "Empty parameter name",
+			patterns:  []string{"/users/{}"},
+			wantPanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); (r != nil) != tt.wantPanic {
+					t.Errorf("add() panic = %v, wantPanic %v", r, tt.wantPanic)
+				}
+			}()
+			n := new(node)
+			methods := []string{http.MethodGet, http.MethodPost}
+			for i, pattern := range tt.patterns {
+				n.add(pattern, methods[i%len(methods)], http.NotFoundHandler())
+			}
+		})
+	}
+}
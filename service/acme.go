@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	// defaultACMERenewBefore represents the default duration to renew the ACME certificates before they expire
+	defaultACMERenewBefore = time.Hour * 24 * 30
+)
+
+var (
+	// ErrACMECertificateOnHandshake represents an error that the ACME certificates are not loaded in advance, since they are obtained on the TLS handshakes
+	ErrACMECertificateOnHandshake = errors.New("ACME certificates are obtained on the TLS handshake")
+
+	// acmeManagers caches the ACME managers keyed by the configuration,
+	// so that the listeners and the reloads of the same configuration share the obtained certificates, the renewals and the pending challenges
+	acmeManagers = struct {
+		sync.Mutex
+		m map[string]*autocert.Manager
+	}{
+		m: make(map[string]*autocert.Manager),
+	}
+)
+
+// acmeSource reads the CA certificate file of the client certificates which path is defined in the environment variable.
+// The server certificates are obtained by the ACME manager on the TLS handshakes.
+type acmeSource struct {
+	ca string
+}
+
+// acmeChallengeServer represents the ACME HTTP-01 challenge server, which handler is swapped when the ACME configuration is reloaded.
+type acmeChallengeServer struct {
+	srv *http.Server
+
+	// handler stores the http.Handler of the current ACME manager
+	handler atomic.Value
+}
+
+// acmeManager returns the ACME manager of cfg, which obtains and renews the certificates of cfg.Domains, and caches them in cfg.CacheDir.
+func acmeManager(cfg config.ACME) *autocert.Manager {
+	acmeManagers.Lock()
+	defer acmeManagers.Unlock()
+
+	key := fmt.Sprintf("%#v", cfg)
+	if m, ok := acmeManagers.m[key]; ok {
+		return m
+	}
+
+	rb, err := parseDuration(cfg.RenewBefore, defaultACMERenewBefore)
+	if err != nil {
+		rb = defaultACMERenewBefore
+	}
+	dir := cfg.DirectoryURL
+	if dir == "" {
+		dir = autocert.DefaultACMEDirectory
+	}
+	m := &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(cfg.CacheDir),
+		HostPolicy:  autocert.HostWhitelist(cfg.Domains...),
+		RenewBefore: rb,
+		Client: &acme.Client{
+			DirectoryURL: dir,
+		},
+		Email: cfg.Email,
+	}
+	acmeManagers.m[key] = m
+	return m
+}
+
+// applyACMETLSConfig makes t serve the certificates obtained by the ACME manager of cfg, and the TLS-ALPN-01 challenge.
+func applyACMETLSConfig(t *tls.Config, cfg config.ACME) {
+	t.GetCertificate = acmeManager(cfg).GetCertificate
+	t.NextProtos = []string{acme.ALPNProto}
+}
+
+// isACMEChallenge returns true when hello is the TLS-ALPN-01 challenge of the ACME server.
+func isACMEChallenge(hello *tls.ClientHelloInfo) bool {
+	return len(hello.SupportedProtos) == 1 && hello.SupportedProtos[0] == acme.ALPNProto
+}
+
+// acmeChallengeHandlers returns the HTTP-01 challenge handlers of the ACME listeners keyed by the port of the challenge server.
+func acmeChallengeHandlers(cfg config.Server) map[int]http.Handler {
+	hs := make(map[int]http.Handler)
+	for _, name := range config.ACMEListenerNames {
+		t := cfg.ListenerTLS(name)
+		if t.Enabled && t.Source == config.CertSourceACME && t.ACME.HTTPPort != 0 {
+			// the handler enables the HTTP-01 challenge of the manager, and redirects the other requests to HTTPS
+			hs[t.ACME.HTTPPort] = acmeManager(t.ACME).HTTPHandler(nil)
+		}
+	}
+	return hs
+}
+
+// newACMEChallengeServer returns the HTTP-01 challenge server of the port, which serves h.
+func newACMEChallengeServer(port int, h http.Handler) *acmeChallengeServer {
+	a := new(acmeChallengeServer)
+	a.handler.Store(h)
+	a.srv = &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: a,
+	}
+	return a
+}
+
+func (a *acmeChallengeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.Load().(http.Handler).ServeHTTP(w, r)
+}
+
+// serveACMEChallenge serves the ACME HTTP-01 challenge server until ctx is done.
+// Its error is logged without stopping the other servers, since the TLS-ALPN-01 challenge is still served by the TLS listeners.
+func (s *server) serveACMEChallenge(ctx context.Context, a *acmeChallengeServer) {
+	go func() {
+		<-ctx.Done()
+		a.srv.Close()
+	}()
+	if err := a.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		s.log.Errorf("failed to serve the ACME HTTP-01 challenge at %s: %v", a.srv.Addr, err)
+	}
+}
+
+// Certificate returns ErrACMECertificateOnHandshake, since the certificates are obtained on the TLS handshakes.
+func (s *acmeSource) Certificate() (tls.Certificate, error) {
+	return tls.Certificate{}, ErrACMECertificateOnHandshake
+}
+
+func (s *acmeSource) CA() ([]byte, error) {
+	if s.ca == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(s.ca)
+}
+
+// Paths returns the CA certificate file, since the obtained certificates are renewed by the ACME manager.
+func (s *acmeSource) Paths() []string {
+	return nonEmpty(s.ca)
+}
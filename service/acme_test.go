@@ -0,0 +1,448 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+)
+
+// acmeStandIn represents the ACME server (RFC 8555) for the tests, which validates the domain by the challenge and issues the certificate of the CSR.
+type acmeStandIn struct {
+	*httptest.Server
+
+	// challenge represents the challenge type offered to the client
+	challenge string
+
+	// addr represents the address of the listener validated by the TLS-ALPN-01 challenge
+	addr string
+
+	// http represents the URL of the server validated by the HTTP-01 challenge
+	http string
+
+	ca    *x509.Certificate
+	caKey *ecdsa.PrivateKey
+
+	mu sync.Mutex
+	// thumbprint represents the JWK thumbprint of the account key, which is a part of the key authorization
+	thumbprint string
+	domain     string
+	valid      bool
+	err        error
+	// chain represents the PEM encoded certificate chain issued by the finalize request
+	chain []byte
+}
+
+// newACMEStandIn returns the started ACME server offering the challenge type.
+func newACMEStandIn(t *testing.T, challenge string) *acmeStandIn {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ACME stand-in CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour * 24),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &acmeStandIn{
+		challenge: challenge,
+		ca:        ca,
+		caKey:     key,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+func (s *acmeStandIn) serve(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Replay-Nonce", fmt.Sprintf("nonce-%d", time.Now().UnixNano()))
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// the JWS payload is empty on the POST-as-GET requests
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+	}
+	var header struct {
+		JWK map[string]string `json:"jwk"`
+	}
+	var req struct {
+		CSR         string `json:"csr"`
+		Identifiers []struct {
+			Value string `json:"value"`
+		} `json:"identifiers"`
+	}
+	if r.Method == http.MethodPost {
+		b, err := ioutil.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(b, &jws)
+		}
+		if err == nil {
+			err = decodeJWSPart(jws.Protected, &header)
+		}
+		if err == nil {
+			err = decodeJWSPart(jws.Payload, &req)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	challenge := map[string]string{
+		"type":   s.challenge,
+		"url":    s.URL + "/challenge",
+		"token":  "token",
+		"status": "pending",
+	}
+	if s.valid {
+		challenge["status"] = "valid"
+	}
+	switch r.URL.Path {
+	case "/directory":
+		json.NewEncoder(w).Encode(map[string]string{
+			"newNonce":   s.URL + "/new-nonce",
+			"newAccount": s.URL + "/new-account",
+			"newOrder":   s.URL + "/new-order",
+			"revokeCert": s.URL + "/revoke-cert",
+			"keyChange":  s.URL + "/key-change",
+		})
+	case "/new-nonce":
+		w.WriteHeader(http.StatusNoContent)
+	case "/new-account":
+		s.thumbprint = jwkThumbprint(header.JWK)
+		w.Header().Set("Location", s.URL+"/account")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"status": "valid"})
+	case "/new-order":
+		if len(req.Identifiers) != 1 {
+			http.Error(w, "one identifier is required", http.StatusBadRequest)
+			return
+		}
+		s.domain, s.valid, s.chain = req.Identifiers[0].Value, false, nil
+		w.Header().Set("Location", s.URL+"/order")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(s.order())
+	case "/order":
+		w.Header().Set("Location", s.URL+"/order")
+		json.NewEncoder(w).Encode(s.order())
+	case "/authz":
+		status := "pending"
+		if s.valid {
+			status = "valid"
+		} else if s.err != nil {
+			status = "invalid"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": status,
+			"identifier": map[string]string{
+				"type":  "dns",
+				"value": s.domain,
+			},
+			"challenges": []interface{}{challenge},
+		})
+	case "/challenge":
+		s.err = s.validate(challenge["token"] + "." + s.thumbprint)
+		s.valid = s.err == nil
+		challenge["status"] = "valid"
+		if !s.valid {
+			challenge["status"] = "invalid"
+		}
+		json.NewEncoder(w).Encode(challenge)
+	case "/finalize":
+		if !s.valid {
+			http.Error(w, "order is not ready", http.StatusForbidden)
+			return
+		}
+		der, err := s.issue(req.CSR)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.chain = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.ca.Raw})...)
+		w.Header().Set("Location", s.URL+"/order")
+		json.NewEncoder(w).Encode(s.order())
+	case "/cert":
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		w.Write(s.chain)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// order returns the current order of the domain, which must be locked by mu.
+func (s *acmeStandIn) order() map[string]interface{} {
+	o := map[string]interface{}{
+		"status": "pending",
+		"identifiers": []map[string]string{{
+			"type":  "dns",
+			"value": s.domain,
+		}},
+		"authorizations": []string{s.URL + "/authz"},
+		"finalize":       s.URL + "/finalize",
+	}
+	switch {
+	case s.chain != nil:
+		o["status"] = "valid"
+		o["certificate"] = s.URL + "/cert"
+	case s.valid:
+		o["status"] = "ready"
+	case s.err != nil:
+		o["status"] = "invalid"
+	}
+	return o
+}
+
+// validate validates the domain by the challenge, which proves the key authorization.
+// It must be called with mu locked.
+func (s *acmeStandIn) validate(keyAuth string) error {
+	switch s.challenge {
+	case "http-01":
+		req, err := http.NewRequest(http.MethodGet, s.http+"/.well-known/acme-challenge/token", nil)
+		if err != nil {
+			return err
+		}
+		req.Host = s.domain
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			return err
+		}
+		if string(b) != keyAuth {
+			return fmt.Errorf("HTTP-01 response = %q, want %q", b, keyAuth)
+		}
+		return nil
+	case "tls-alpn-01":
+		conn, err := tls.Dial("tcp", s.addr, &tls.Config{
+			ServerName:         s.domain,
+			NextProtos:         []string{"acme-tls/1"},
+			InsecureSkipVerify: true,
+		})
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		st := conn.ConnectionState()
+		if st.NegotiatedProtocol != "acme-tls/1" || len(st.PeerCertificates) == 0 {
+			return fmt.Errorf("TLS-ALPN-01 negotiated %q", st.NegotiatedProtocol)
+		}
+		sum := sha256.Sum256([]byte(keyAuth))
+		want, err := asn1.Marshal(sum[:])
+		if err != nil {
+			return err
+		}
+		for _, ext := range st.PeerCertificates[0].Extensions {
+			// the acmeIdentifier extension of RFC 8737
+			if ext.Id.Equal(asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 31}) && string(ext.Value) == string(want) {
+				return nil
+			}
+		}
+		return fmt.Errorf("TLS-ALPN-01 certificate has no acmeIdentifier of the key authorization")
+	}
+	return fmt.Errorf("unsupported challenge %q", s.challenge)
+}
+
+// decodeJWSPart decodes the base64url encoded JSON of the JWS protected header or payload to v, the empty part is left as it is.
+func decodeJWSPart(part string, v interface{}) error {
+	if part == "" {
+		return nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// jwkThumbprint returns the JWK thumbprint (RFC 7638) of the EC or RSA public key.
+func jwkThumbprint(jwk map[string]string) string {
+	var b string
+	switch jwk["kty"] {
+	case "EC":
+		b = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, jwk["crv"], jwk["x"], jwk["y"])
+	case "RSA":
+		b = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, jwk["e"], jwk["n"])
+	}
+	sum := sha256.Sum256([]byte(b))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// issue returns the certificate of the CSR signed by the CA.
+func (s *acmeStandIn) issue(csr string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(csr)
+	if err != nil {
+		return nil, err
+	}
+	req, err := x509.ParseCertificateRequest(b)
+	if err != nil {
+		return nil, err
+	}
+	// the common name is also the subject alternative name, as the public ACME servers do
+	sans := req.DNSNames
+	if len(sans) == 0 {
+		sans = []string{req.Subject.CommonName}
+	}
+	return x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: req.Subject.CommonName},
+		DNSNames:     sans,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 12).Truncate(time.Second),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, s.ca, req.PublicKey, s.caKey)
+}
+
+func Test_certManager_ACME(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		domain    string
+	}{
+		{
+			name:      "Certificate is obtained by the HTTP-01 challenge",
+			challenge: "http-01",
+			domain:    "http01.example.com",
+		},
+		{
+			name:      "Certificate is obtained by the TLS-ALPN-01 challenge",
+			challenge: "tls-alpn-01",
+			domain:    "tlsalpn01.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "acme")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			ca := newACMEStandIn(t, tt.challenge)
+			defer ca.Close()
+
+			cfg := config.Server{
+				TLS: config.TLS{
+					Enabled: true,
+					Source:  config.CertSourceACME,
+					ACME: config.ACME{
+						Domains:      []string{tt.domain},
+						DirectoryURL: ca.URL + "/directory",
+						CacheDir:     dir,
+						HTTPPort:     80,
+					},
+				},
+			}
+			for _, h := range acmeChallengeHandlers(cfg) {
+				hs := httptest.NewServer(h)
+				defer hs.Close()
+				ca.http = hs.URL
+			}
+
+			m := &expiryMetrics{expiry: make(map[string]time.Time)}
+			c := NewCertManager(config.ListenerREST, m, logger.Nop())
+			if err := c.Reload(cfg.ListenerTLS(config.ListenerREST)); err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			if err := c.Check(context.Background()); err != nil {
+				t.Errorf("Check() before the first handshake error = %v", err)
+			}
+
+			l, err := tls.Listen("tcp", "127.0.0.1:0", c.TLSConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			ca.addr = l.Addr().String()
+			go func() {
+				for {
+					conn, err := l.Accept()
+					if err != nil {
+						return
+					}
+					go func(conn net.Conn) {
+						defer conn.Close()
+						conn.(*tls.Conn).Handshake()
+					}(conn)
+				}
+			}()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.ca)
+			conn, err := tls.Dial("tcp", ca.addr, &tls.Config{
+				ServerName: tt.domain,
+				RootCAs:    roots,
+			})
+			if err != nil {
+				ca.mu.Lock()
+				t.Fatalf("handshake error = %v, challenge error = %v", err, ca.err)
+			}
+			leaf := conn.ConnectionState().PeerCertificates[0]
+			conn.Close()
+
+			if got := c.NotAfter(); !got.Equal(leaf.NotAfter) {
+				t.Errorf("NotAfter() = %v, want %v", got, leaf.NotAfter)
+			}
+			if got := m.expiry["rest "+tt.domain]; !got.Equal(leaf.NotAfter) {
+				t.Errorf("ObserveCertificateExpiry() = %v, want %v", got, leaf.NotAfter)
+			}
+			if d, ok := c.(*certManager).Details().([]certHealthDetails); !ok || len(d) != 1 || d[0].Certificate != tt.domain {
+				t.Errorf("Details() = %v", d)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.domain)); err != nil {
+				t.Errorf("the obtained certificate is not cached: %v", err)
+			}
+
+			if _, err := tls.Dial("tcp", ca.addr, &tls.Config{
+				ServerName: "other.example.com",
+				RootCAs:    roots,
+			}); err == nil {
+				t.Errorf("handshake of the unknown domain error = %v, want the rejection", err)
+			}
+		})
+	}
+}
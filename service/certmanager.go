@@ -0,0 +1,422 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/pkg/errors"
+)
+
+const (
+	// certHealthCheckName represents the name prefix of the certificate check shown in the health check response, which is followed by the listener name
+	certHealthCheckName = "tls_certificate_"
+
+	// defaultCertWatchInterval represents the default interval to poll the certificate files
+	defaultCertWatchInterval = time.Second * 10
+)
+
+var (
+	// ErrCertificateNotLoaded represents an error that no certificate is loaded by the certificate manager
+	ErrCertificateNotLoaded = errors.New("TLS certificate is not loaded")
+
+	// ErrCertificateExpired represents an error that the current certificate is expired
+	ErrCertificateExpired = errors.New("TLS certificate is expired")
+)
+
+// CertManager represents the TLS certificates of a listener, which are reloaded without restarting the listener.
+// It is also a HealthChecker, which reports the expiry of the current certificates.
+type CertManager interface {
+	HealthChecker
+
+	// Reload loads the certificate, the private key and the CA certificate defined by cfg, and replaces the current ones atomically.
+	// The current ones are kept serving when any of them cannot be loaded.
+	Reload(cfg config.TLS) error
+
+	// Prepare loads the certificate, the private key and the CA certificate defined by cfg, and returns the function which replaces the current ones with them atomically.
+	// The current ones are not changed until the returned function is called, so that several listeners can be reloaded all or nothing.
+	Prepare(cfg config.TLS) (func(), error)
+
+	// Watch polls the files of the current certificates, and reloads them when any of the file contents is changed, until ctx is done.
+	Watch(ctx context.Context)
+
+	// TLSConfig returns the *tls.Config of a listener, which always serves the current certificates.
+	TLSConfig() *tls.Config
+
+	// GetCertificate returns the current server certificate selected by the SNI hostname, or the one obtained for the hostname, e.g. by ACME.
+	// It is used as tls.Config.GetCertificate.
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// GetConfigForClient returns the current *tls.Config, it is used as tls.Config.GetConfigForClient.
+	GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error)
+
+	// NotAfter returns the earliest expiry of the current server certificates, or the zero time when no certificate is loaded.
+	NotAfter() time.Time
+}
+
+type certManager struct {
+	// mu serializes the swaps of the certificates
+	mu sync.Mutex
+
+	// current stores the current *certificates, which is swapped atomically
+	current atomic.Value
+
+	// changed is notified when the watched files or the interval are changed by Reload
+	changed chan struct{}
+
+	// listener represents the name of the listener which serves the certificates
+	listener string
+
+	// obtained represents the certificates obtained on the handshakes keyed by the name, which are guarded by omu
+	omu      sync.Mutex
+	obtained map[string]*x509.Certificate
+
+	metrics metrics.Metrics
+	log     logger.Logger
+}
+
+// certificates represents the loaded TLS configuration and the source of it.
+type certificates struct {
+	cfg  config.TLS
+	tcfg *tls.Config
+
+	// notAfter represents the earliest expiry of the certificates
+	notAfter time.Time
+
+	// getCertificate obtains the certificate on the handshake, e.g. by ACME, it is nil when the certificates are loaded in advance
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+}
+
+// certHealthDetails represents the details of a certificate shown in the health check response.
+type certHealthDetails struct {
+	Certificate string `json:"certificate"`
+	NotAfter    string `json:"not_after"`
+	ExpiresIn   string `json:"expires_in"`
+}
+
+// NewCertManager returns CertManager of the listener, which has no certificate until Reload is called.
+// The expiry of the loaded certificate is recorded to m unless m is nil.
+func NewCertManager(listener string, m metrics.Metrics, l logger.Logger) CertManager {
+	return &certManager{
+		changed:  make(chan struct{}, 1),
+		listener: listener,
+		metrics:  m,
+		log:      l,
+	}
+}
+
+func (c *certManager) Name() string {
+	return certHealthCheckName + c.listener
+}
+
+// Check returns error when no certificate is loaded or any of the current certificates is expired.
+// The certificates obtained on the handshakes are not required until the first handshake.
+func (c *certManager) Check(context.Context) error {
+	na := c.NotAfter()
+	if na.IsZero() {
+		if cur := c.load(); cur != nil && cur.getCertificate != nil {
+			return nil
+		}
+		return ErrCertificateNotLoaded
+	}
+	if time.Now().After(na) {
+		return errors.Wrapf(ErrCertificateExpired, "expired at %s", na.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Details returns the expiry of each current certificate shown in the health check response.
+func (c *certManager) Details() interface{} {
+	cur := c.load()
+	if cur == nil {
+		return nil
+	}
+	leaves := c.leaves(cur)
+	ds := make([]certHealthDetails, 0, len(leaves))
+	for _, leaf := range leaves {
+		ds = append(ds, certHealthDetails{
+			Certificate: certName(leaf),
+			NotAfter:    leaf.NotAfter.Format(time.RFC3339),
+			ExpiresIn:   time.Until(leaf.NotAfter).Truncate(time.Second).String(),
+		})
+	}
+	return ds
+}
+
+func (c *certManager) Reload(cfg config.TLS) error {
+	swap, err := c.Prepare(cfg)
+	if err != nil {
+		return err
+	}
+	swap()
+	return nil
+}
+
+func (c *certManager) Prepare(cfg config.TLS) (func(), error) {
+	tcfg, err := NewTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the protocols of the source, e.g. the ACME TLS-ALPN-01 challenge, are negotiated only when the client requests them
+	tcfg.NextProtos = append(append([]string{}, nextProtos...), tcfg.NextProtos...)
+
+	var get func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	if len(tcfg.Certificates) == 0 && tcfg.GetCertificate != nil {
+		// the obtained certificates are recorded by GetCertificate
+		get, tcfg.GetCertificate = tcfg.GetCertificate, c.GetCertificate
+	}
+
+	var notAfter time.Time
+	for i := range tcfg.Certificates {
+		leaf, err := x509.ParseCertificate(tcfg.Certificates[i].Certificate[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the TLS certificate")
+		}
+		tcfg.Certificates[i].Leaf = leaf
+		if notAfter.IsZero() || leaf.NotAfter.Before(notAfter) {
+			notAfter = leaf.NotAfter
+		}
+	}
+
+	next := &certificates{
+		cfg:            cfg,
+		tcfg:           tcfg,
+		notAfter:       notAfter,
+		getCertificate: get,
+	}
+	return func() {
+		c.swap(next)
+	}, nil
+}
+
+// swap replaces the current certificates with next, and reports the loaded certificates.
+func (c *certManager) swap(next *certificates) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if next.getCertificate == nil {
+		c.omu.Lock()
+		c.obtained = nil
+		c.omu.Unlock()
+	}
+
+	prev := c.load()
+	c.current.Store(next)
+
+	for _, crt := range next.tcfg.Certificates {
+		if prev == nil || !contains(prev.tcfg.Certificates, crt.Leaf) {
+			c.log.Infof("loaded the TLS certificate %q of the %s listener, which expires at %s", crt.Leaf.Subject.String(), c.listener, crt.Leaf.NotAfter.Format(time.RFC3339))
+		}
+		if c.metrics != nil {
+			c.metrics.ObserveCertificateExpiry(c.listener, certName(crt.Leaf), crt.Leaf.NotAfter)
+		}
+	}
+	if prev != nil && (prev.cfg.WatchInterval != next.cfg.WatchInterval || !reflect.DeepEqual(certPaths(prev.cfg), certPaths(next.cfg))) {
+		select {
+		case c.changed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *certManager) Watch(ctx context.Context) {
+	for {
+		var notify <-chan struct{}
+		wctx, cancel := context.WithCancel(ctx)
+		if cur := c.load(); cur != nil {
+			dur, err := parseDuration(cur.cfg.WatchInterval, defaultCertWatchInterval)
+			if err != nil {
+				dur = defaultCertWatchInterval
+			}
+			if dur > 0 {
+				notify = config.Watch(wctx, dur, certPaths(cur.cfg)...)
+			}
+		}
+
+		restart := c.watch(ctx, notify)
+		cancel()
+		if !restart {
+			return
+		}
+	}
+}
+
+// watch reloads the current certificates whenever notify receives a notification.
+// It returns true when the watched files are changed by Reload, so that the watch must be restarted, and false when ctx is done.
+func (c *certManager) watch(ctx context.Context, notify <-chan struct{}) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-c.changed:
+			return true
+		case <-notify:
+			cur := c.load()
+			if cur == nil {
+				continue
+			}
+			if err := c.Reload(cur.cfg); err != nil {
+				c.log.Errorf("failed to reload the TLS certificate of the %s listener, the current certificate is kept: %v", c.listener, err)
+			}
+		}
+	}
+}
+
+func (c *certManager) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate:     c.GetCertificate,
+		GetConfigForClient: c.GetConfigForClient,
+	}
+}
+
+func (c *certManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cur := c.load()
+	if cur == nil {
+		return nil, ErrCertificateNotLoaded
+	}
+	if cur.getCertificate != nil {
+		if hello == nil {
+			return nil, ErrCertificateNotLoaded
+		}
+		crt, err := cur.getCertificate(hello)
+		if err != nil {
+			return nil, err
+		}
+		if !isACMEChallenge(hello) {
+			c.observe(crt)
+		}
+		return crt, nil
+	}
+	if hello != nil && hello.ServerName != "" {
+		for i, crt := range cur.tcfg.Certificates {
+			if crt.Leaf.VerifyHostname(hello.ServerName) == nil {
+				return &cur.tcfg.Certificates[i], nil
+			}
+		}
+	}
+	return &cur.tcfg.Certificates[0], nil
+}
+
+func (c *certManager) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cur := c.load()
+	if cur == nil {
+		return nil, ErrCertificateNotLoaded
+	}
+	return cur.tcfg, nil
+}
+
+func (c *certManager) NotAfter() time.Time {
+	cur := c.load()
+	if cur == nil {
+		return time.Time{}
+	}
+	if cur.getCertificate == nil {
+		return cur.notAfter
+	}
+	var notAfter time.Time
+	for _, leaf := range c.leaves(cur) {
+		if notAfter.IsZero() || leaf.NotAfter.Before(notAfter) {
+			notAfter = leaf.NotAfter
+		}
+	}
+	return notAfter
+}
+
+// observe records the certificate obtained on the handshake, and reports it when it is new.
+func (c *certManager) observe(crt *tls.Certificate) {
+	leaf := crt.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(crt.Certificate[0]); err != nil {
+			return
+		}
+	}
+	name := certName(leaf)
+
+	c.omu.Lock()
+	prev, ok := c.obtained[name]
+	if c.obtained == nil {
+		c.obtained = make(map[string]*x509.Certificate)
+	}
+	c.obtained[name] = leaf
+	c.omu.Unlock()
+
+	if ok && prev.Equal(leaf) {
+		return
+	}
+	c.log.Infof("obtained the TLS certificate %q of the %s listener, which expires at %s", leaf.Subject.String(), c.listener, leaf.NotAfter.Format(time.RFC3339))
+	if c.metrics != nil {
+		c.metrics.ObserveCertificateExpiry(c.listener, name, leaf.NotAfter)
+	}
+}
+
+// leaves returns the leaf certificates of cur, which are the obtained ones sorted by the name when they are obtained on the handshakes.
+func (c *certManager) leaves(cur *certificates) []*x509.Certificate {
+	if cur.getCertificate == nil {
+		leaves := make([]*x509.Certificate, 0, len(cur.tcfg.Certificates))
+		for _, crt := range cur.tcfg.Certificates {
+			leaves = append(leaves, crt.Leaf)
+		}
+		return leaves
+	}
+
+	c.omu.Lock()
+	defer c.omu.Unlock()
+	names := make([]string, 0, len(c.obtained))
+	for name := range c.obtained {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	leaves := make([]*x509.Certificate, 0, len(names))
+	for _, name := range names {
+		leaves = append(leaves, c.obtained[name])
+	}
+	return leaves
+}
+
+// load returns the current certificates, or nil when no certificate is loaded.
+func (c *certManager) load() *certificates {
+	cur, _ := c.current.Load().(*certificates)
+	return cur
+}
+
+// certPaths returns the files of the certificates, the private keys and the CA certificate defined by cfg.
+func certPaths(cfg config.TLS) []string {
+	var paths []string
+	if src, err := NewCertSource(cfg); err == nil {
+		paths = append(paths, src.Paths()...)
+	}
+	for _, c := range cfg.Certificates {
+		if src, err := NewCertSource(sniConfig(cfg, c)); err == nil {
+			paths = append(paths, src.Paths()...)
+		}
+	}
+	return paths
+}
+
+// certName returns the name of the certificate, which is the common name or the first DNS name.
+func certName(leaf *x509.Certificate) string {
+	if leaf.Subject.CommonName != "" || len(leaf.DNSNames) == 0 {
+		return leaf.Subject.CommonName
+	}
+	return leaf.DNSNames[0]
+}
+
+// contains returns true when crts contains the certificate of leaf.
+func contains(crts []tls.Certificate, leaf *x509.Certificate) bool {
+	for _, crt := range crts {
+		if bytes.Equal(crt.Certificate[0], leaf.Raw) {
+			return true
+		}
+	}
+	return false
+}
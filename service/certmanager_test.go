@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/pkg/errors"
+)
+
+// writeCertificate writes the self-signed certificate of the common name and its private key to the files in dir.
+func writeCertificate(t *testing.T, dir, cn string, notAfter time.Time) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    notAfter.Add(-time.Hour * 24),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: cn}}, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kder, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, b := range map[string]*pem.Block{
+		"tls.crt": {Type: "CERTIFICATE", Bytes: der},
+		"tls.key": {Type: "EC PRIVATE KEY", Bytes: kder},
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), pem.EncodeToMemory(b), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// certConfig returns config.TLS which environment variables point to the files written by writeCertificate.
+func certConfig(t *testing.T, name, dir string) config.TLS {
+	t.Helper()
+	cfg := config.TLS{
+		Enabled:       true,
+		CertKey:       "test_" + name + "_cert",
+		KeyKey:        "test_" + name + "_key",
+		WatchInterval: "10ms",
+	}
+	for key, path := range map[string]string{
+		cfg.CertKey: filepath.Join(dir, "tls.crt"),
+		cfg.KeyKey:  filepath.Join(dir, "tls.key"),
+	} {
+		if err := os.Setenv(key, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg
+}
+
+// expiryMetrics records ObserveCertificateExpiry calls.
+type expiryMetrics struct {
+	metrics.Metrics
+	expiry map[string]time.Time
+}
+
+func (m *expiryMetrics) ObserveCertificateExpiry(listener, certificate string, notAfter time.Time) {
+	m.expiry[listener+" "+certificate] = notAfter
+}
+
+func Test_certManager_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notAfter := time.Now().Add(time.Hour * 24).Truncate(time.Second).UTC()
+	writeCertificate(t, dir, "first.example.com", notAfter)
+	cfg := certConfig(t, "certManager_Reload", dir)
+
+	m := &expiryMetrics{expiry: make(map[string]time.Time)}
+	c := NewCertManager(config.ListenerREST, m, logger.Nop())
+	if err := c.Check(context.Background()); err != ErrCertificateNotLoaded {
+		t.Errorf("Check() error = %v, want %v", err, ErrCertificateNotLoaded)
+	}
+	if err := c.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := c.NotAfter(); !got.Equal(notAfter) {
+		t.Errorf("NotAfter() = %v, want %v", got, notAfter)
+	}
+	if got := m.expiry["rest first.example.com"]; !got.Equal(notAfter) {
+		t.Errorf("ObserveCertificateExpiry() = %v, want %v", got, notAfter)
+	}
+	if err := c.Check(context.Background()); err != nil {
+		t.Errorf("Check() error = %v", err)
+	}
+
+	missing := cfg
+	missing.CertKey = "test_certManager_Reload_missing"
+	if err := c.Reload(missing); err == nil {
+		t.Error("Reload() error = nil, want the missing certificate error")
+	}
+	crt, err := c.GetCertificate(nil)
+	if err != nil || crt.Leaf.Subject.CommonName != "first.example.com" {
+		t.Errorf("GetCertificate() after the failed reload = %v, error = %v", crt, err)
+	}
+	tcfg, err := c.GetConfigForClient(nil)
+	if err != nil || len(tcfg.NextProtos) == 0 {
+		t.Errorf("GetConfigForClient() = %v, error = %v", tcfg, err)
+	}
+}
+
+func Test_certManager_GetCertificate(t *testing.T) {
+	dirs := make([]string, 2)
+	for i := range dirs {
+		dir, err := ioutil.TempDir("", "certmanager")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		dirs[i] = dir
+	}
+	writeCertificate(t, dirs[0], "first.example.com", time.Now().Add(time.Hour*2))
+	writeCertificate(t, dirs[1], "second.example.com", time.Now().Add(time.Hour))
+
+	cfg := certConfig(t, "certManager_GetCertificate_first", dirs[0])
+	second := certConfig(t, "certManager_GetCertificate_second", dirs[1])
+	cfg.Certificates = []config.Certificate{{
+		CertKey: second.CertKey,
+		KeyKey:  second.KeyKey,
+	}}
+	c := NewCertManager(config.ListenerREST, nil, logger.Nop())
+	if err := c.Reload(cfg); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got, want := c.NotAfter(), c.(*certManager).load().tcfg.Certificates[1].Leaf.NotAfter; !got.Equal(want) {
+		t.Errorf("NotAfter() = %v, want the earliest expiry %v", got, want)
+	}
+
+	tests := []struct {
+		serverName string
+		want       string
+	}{
+		{
+			serverName: "second.example.com",
+			want:       "second.example.com",
+		},
+		{
+			serverName: "first.example.com",
+			want:       "first.example.com",
+		},
+		{
+			serverName: "unknown.example.com",
+			want:       "first.example.com",
+		},
+		{
+			want: "first.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serverName, func(t *testing.T) {
+			crt, err := c.GetCertificate(&tls.ClientHelloInfo{ServerName: tt.serverName})
+			if err != nil || crt.Leaf.Subject.CommonName != tt.want {
+				t.Errorf("GetCertificate() = %v, error = %v, want %v", crt, err, tt.want)
+			}
+		})
+	}
+}
+
+func Test_certManager_Watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeCertificate(t, dir, "first.example.com", time.Now().Add(time.Hour))
+	c := NewCertManager(config.ListenerREST, nil, logger.Nop())
+	if err := c.Reload(certConfig(t, "certManager_Watch", dir)); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.Watch(ctx)
+	// wait for the watch to take the checksum of the current files
+	time.Sleep(100 * time.Millisecond)
+
+	writeCertificate(t, dir, "second.example.com", time.Now().Add(time.Hour*2))
+	for i := 0; i < 200; i++ {
+		if crt, err := c.GetCertificate(nil); err == nil && crt.Leaf.Subject.CommonName == "second.example.com" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Watch() did not reload the changed certificate")
+}
+
+func Test_certManager_health(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certmanager")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	notAfter := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	writeCertificate(t, dir, "expired.example.com", notAfter)
+	c := NewCertManager(config.ListenerREST, nil, logger.Nop())
+	if err := c.Reload(certConfig(t, "certManager_health", dir)); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	h := &health{log: logger.Nop()}
+	h.register(ReadinessProbe, c)
+	res := h.check(context.Background(), ReadinessProbe)
+	if res.Status != healthStatusUnavailable {
+		t.Errorf("check() status = %v, want %v", res.Status, healthStatusUnavailable)
+	}
+	cr := res.Checks[len(res.Checks)-1]
+	d, ok := cr.Details.([]certHealthDetails)
+	if cr.Name != "tls_certificate_rest" || !ok || len(d) != 1 || d[0].Certificate != "expired.example.com" || d[0].NotAfter != notAfter.Format(time.RFC3339) {
+		t.Errorf("check() result = %+v", cr)
+	}
+	if err := c.Check(context.Background()); errors.Cause(err) != ErrCertificateExpired {
+		t.Errorf("Check() error = %v, want %v", err, ErrCertificateExpired)
+	}
+}
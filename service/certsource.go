@@ -0,0 +1,315 @@
+package service
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/devcert"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pkcs12"
+)
+
+const (
+	// secretCertFile represents the certificate file name of the kubernetes.io/tls secret
+	secretCertFile = "tls.crt"
+
+	// secretKeyFile represents the private key file name of the kubernetes.io/tls secret
+	secretKeyFile = "tls.key"
+
+	// secretCAFile represents the CA certificate file name of the kubernetes.io/tls secret
+	secretCAFile = "ca.crt"
+
+	// devServerName represents the file name without the extension of the server certificate written by the dev source
+	devServerName = "server"
+)
+
+var (
+	// ErrPKCS12KeyPairNotFound represents an error that the PKCS#12 bundle has no certificate matching its private key
+	ErrPKCS12KeyPairNotFound = errors.New("PKCS#12 bundle has no certificate of the private key")
+
+	// devCerts caches the certificates generated by the dev source, so that the reloads keep serving the certificate issued by the same CA
+	devCerts = struct {
+		sync.Mutex
+		m map[string]tls.Certificate
+	}{
+		m: make(map[string]tls.Certificate),
+	}
+)
+
+// CertSource represents where the certificate, the private key and the CA certificate are read from.
+type CertSource interface {
+	// Certificate returns the certificate chain and the private key.
+	Certificate() (tls.Certificate, error)
+
+	// CA returns the PEM encoded CA certificate, or nil when it is not defined.
+	CA() ([]byte, error)
+
+	// Paths returns the files which the source reads, so that the changes of them are watched.
+	Paths() []string
+}
+
+// fileSource reads the files which paths are defined in the environment variables.
+type fileSource struct {
+	cert, key, ca string
+}
+
+// envSource reads the inline PEM contents of the environment variables.
+type envSource struct {
+	cert, key, ca string
+}
+
+// secretSource reads the directory of the mounted kubernetes.io/tls secret.
+type secretSource struct {
+	dir string
+}
+
+// pkcs12Source reads the PKCS#12 bundle file which path is defined in the environment variable, and the CA certificate file.
+type pkcs12Source struct {
+	path, password, ca string
+}
+
+// devSource generates the CA and the server certificate of the hosts on the first use.
+// The CA certificate of the client certificates is the file which path is defined in the environment variable.
+type devSource struct {
+	cfg config.DevCertificate
+	ca  string
+}
+
+// NewCertSource returns CertSource of cfg.Source, which reads the certificate defined by cfg.
+// It returns ErrTLSCertOrKeyNotFound when the environment variable of the certificate or the private key is not defined.
+func NewCertSource(cfg config.TLS) (CertSource, error) {
+	switch cfg.Source {
+	case "", config.CertSourceFile:
+		s := &fileSource{
+			cert: os.Getenv(cfg.CertKey),
+			key:  os.Getenv(cfg.KeyKey),
+			ca:   os.Getenv(cfg.CAKey),
+		}
+		if s.cert == "" || s.key == "" {
+			return nil, ErrTLSCertOrKeyNotFound
+		}
+		return s, nil
+	case config.CertSourceEnv:
+		s := &envSource{
+			cert: os.Getenv(cfg.CertKey),
+			key:  os.Getenv(cfg.KeyKey),
+			ca:   os.Getenv(cfg.CAKey),
+		}
+		if s.cert == "" || s.key == "" {
+			return nil, ErrTLSCertOrKeyNotFound
+		}
+		return s, nil
+	case config.CertSourceSecret:
+		if cfg.SecretDir == "" {
+			return nil, ErrTLSCertOrKeyNotFound
+		}
+		return &secretSource{
+			dir: cfg.SecretDir,
+		}, nil
+	case config.CertSourcePKCS12:
+		s := &pkcs12Source{
+			path:     os.Getenv(cfg.CertKey),
+			password: cfg.PKCS12Password,
+			ca:       os.Getenv(cfg.CAKey),
+		}
+		if s.path == "" {
+			return nil, ErrTLSCertOrKeyNotFound
+		}
+		return s, nil
+	case config.CertSourceDev:
+		return &devSource{
+			cfg: cfg.Dev,
+			ca:  os.Getenv(cfg.CAKey),
+		}, nil
+	case config.CertSourceACME:
+		return &acmeSource{
+			ca: os.Getenv(cfg.CAKey),
+		}, nil
+	}
+	return nil, errors.Errorf("unsupported certificate source %q", cfg.Source)
+}
+
+func (s *fileSource) Certificate() (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(s.cert, s.key)
+}
+
+func (s *fileSource) CA() ([]byte, error) {
+	if s.ca == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(s.ca)
+}
+
+func (s *fileSource) Paths() []string {
+	return nonEmpty(s.cert, s.key, s.ca)
+}
+
+func (s *envSource) Certificate() (tls.Certificate, error) {
+	return tls.X509KeyPair([]byte(s.cert), []byte(s.key))
+}
+
+func (s *envSource) CA() ([]byte, error) {
+	if s.ca == "" {
+		return nil, nil
+	}
+	return []byte(s.ca), nil
+}
+
+// Paths returns nil, since the environment variables are not changed while the process is running.
+func (s *envSource) Paths() []string {
+	return nil
+}
+
+func (s *secretSource) Certificate() (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(filepath.Join(s.dir, secretCertFile), filepath.Join(s.dir, secretKeyFile))
+}
+
+// CA returns the optional ca.crt of the secret, or nil when the secret does not have it.
+func (s *secretSource) CA() ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, secretCAFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// Paths returns the directory of the secret, so that ca.crt added to or removed from the secret, and the swap of the ..data symbolic link, are also watched.
+func (s *secretSource) Paths() []string {
+	return []string{s.dir}
+}
+
+// Certificate returns the certificate of the private key in the bundle, which chain is the other certificates in the bundle.
+func (s *pkcs12Source) Certificate() (tls.Certificate, error) {
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	blocks, err := pkcs12.ToPEM(b, s.password)
+	if err != nil {
+		return tls.Certificate{}, errors.Wrap(err, "failed to decode the PKCS#12 bundle")
+	}
+
+	var key []byte
+	certs := make([][]byte, 0, len(blocks))
+	for _, b := range blocks {
+		// the headers of the bag attributes are not the part of the PEM encoded certificate and key
+		b.Headers = nil
+		switch b.Type {
+		case "CERTIFICATE":
+			certs = append(certs, pem.EncodeToMemory(b))
+		case "PRIVATE KEY":
+			key = pem.EncodeToMemory(b)
+		}
+	}
+
+	// the certificate of the private key is the leaf, which is not always the first one in the bundle
+	for i := range certs {
+		chain := append(append([]byte{}, certs[i]...), concat(certs[:i], certs[i+1:])...)
+		if crt, err := tls.X509KeyPair(chain, key); err == nil {
+			return crt, nil
+		}
+	}
+	return tls.Certificate{}, ErrPKCS12KeyPairNotFound
+}
+
+func (s *pkcs12Source) CA() ([]byte, error) {
+	if s.ca == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(s.ca)
+}
+
+func (s *pkcs12Source) Paths() []string {
+	return nonEmpty(s.path, s.ca)
+}
+
+// Certificate returns the server certificate issued by the generated CA, which is generated once per the configuration.
+// The CA certificate and the server certificate are written to the directory when it is defined.
+func (s *devSource) Certificate() (tls.Certificate, error) {
+	devCerts.Lock()
+	defer devCerts.Unlock()
+
+	key := strings.Join([]string{strings.Join(s.cfg.Hosts, ","), s.cfg.ValidFor, s.cfg.Dir}, "|")
+	if crt, ok := devCerts.m[key]; ok {
+		return crt, nil
+	}
+
+	validFor, err := parseDuration(s.cfg.ValidFor, devcert.DefaultValidFor)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	ca, err := devcert.NewCA(devcert.CAName, validFor)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	kp, err := ca.Server(s.cfg.Hosts, validFor)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if s.cfg.Dir != "" {
+		if err := kp.Write(s.cfg.Dir, devServerName); err != nil {
+			return tls.Certificate{}, errors.Wrap(err, "failed to write the generated server certificate")
+		}
+		if err := ioutil.WriteFile(filepath.Join(s.cfg.Dir, secretCAFile), ca.Cert, 0644); err != nil {
+			return tls.Certificate{}, errors.Wrap(err, "failed to write the generated CA certificate")
+		}
+	}
+
+	crt, err := kp.TLSCertificate()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	devCerts.m[key] = crt
+	return crt, nil
+}
+
+// CA returns the CA certificate of the client certificates, e.g. the one generated by the certs subcommand, or nil when it is not defined.
+// The generated CA of the server certificate does not issue any client certificate.
+func (s *devSource) CA() ([]byte, error) {
+	if s.ca == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(s.ca)
+}
+
+// Paths returns the CA certificate file, since the generated certificates are not read from any file.
+func (s *devSource) Paths() []string {
+	return nonEmpty(s.ca)
+}
+
+// sniConfig returns the configuration of the additional certificate c, which is read from the same source as cfg without the CA certificate.
+func sniConfig(cfg config.TLS, c config.Certificate) config.TLS {
+	cfg.CertKey = c.CertKey
+	cfg.KeyKey = c.KeyKey
+	cfg.SecretDir = c.SecretDir
+	cfg.CAKey = ""
+	return cfg
+}
+
+// nonEmpty returns the non-empty values of vals.
+func nonEmpty(vals ...string) []string {
+	res := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// concat returns the concatenation of the PEM blocks.
+func concat(blocks ...[][]byte) []byte {
+	var b []byte
+	for _, bs := range blocks {
+		for _, p := range bs {
+			b = append(b, p...)
+		}
+	}
+	return b
+}
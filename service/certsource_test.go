@@ -0,0 +1,222 @@
+package service
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kpango/golang-server-template/config"
+)
+
+func TestNewCertSource(t *testing.T) {
+	read := func(path string) string {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	dir, err := ioutil.TempDir("", "certsource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for name, path := range map[string]string{
+		secretCertFile: "./assets/dummyServer.crt",
+		secretKeyFile:  "./assets/dummyServer.key",
+		secretCAFile:   "./assets/dummyCa.pem",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(read(path)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		cfg       config.TLS
+		env       map[string]string
+		wantCA    bool
+		wantPaths int
+		wantErr   bool
+	}{
+		{
+			name: "File source reads the files of the environment variables",
+			cfg: config.TLS{
+				CertKey: "test_CertSource_file_cert",
+				KeyKey:  "test_CertSource_file_key",
+				CAKey:   "test_CertSource_file_ca",
+			},
+			env: map[string]string{
+				"test_CertSource_file_cert": "./assets/dummyServer.crt",
+				"test_CertSource_file_key":  "./assets/dummyServer.key",
+				"test_CertSource_file_ca":   "./assets/dummyCa.pem",
+			},
+			wantCA:    true,
+			wantPaths: 3,
+		},
+		{
+			name: "Env source reads the inline PEM of the environment variables",
+			cfg: config.TLS{
+				Source:  config.CertSourceEnv,
+				CertKey: "test_CertSource_env_cert",
+				KeyKey:  "test_CertSource_env_key",
+			},
+			env: map[string]string{
+				"test_CertSource_env_cert": read("./assets/dummyServer.crt"),
+				"test_CertSource_env_key":  read("./assets/dummyServer.key"),
+			},
+		},
+		{
+			name: "Secret source reads the kubernetes.io/tls secret directory",
+			cfg: config.TLS{
+				Source:    config.CertSourceSecret,
+				SecretDir: dir,
+			},
+			wantCA:    true,
+			wantPaths: 1,
+		},
+		{
+			name: "PKCS#12 source decrypts the bundle by the password",
+			cfg: config.TLS{
+				Source:         config.CertSourcePKCS12,
+				CertKey:        "test_CertSource_pkcs12",
+				PKCS12Password: "dummy",
+			},
+			env: map[string]string{
+				"test_CertSource_pkcs12": "./assets/dummyServer.p12",
+			},
+			wantPaths: 1,
+		},
+		{
+			name: "PKCS#12 source with the wrong password is error",
+			cfg: config.TLS{
+				Source:         config.CertSourcePKCS12,
+				CertKey:        "test_CertSource_pkcs12",
+				PKCS12Password: "wrong",
+			},
+			env: map[string]string{
+				"test_CertSource_pkcs12": "./assets/dummyServer.p12",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Dev source generates the certificate and writes it to the directory",
+			cfg: config.TLS{
+				Source: config.CertSourceDev,
+				Dev: config.DevCertificate{
+					Hosts: []string{"localhost", "127.0.0.1"},
+					Dir:   filepath.Join(dir, "dev"),
+				},
+			},
+		},
+		{
+			name: "Dev source with the invalid duration is error",
+			cfg: config.TLS{
+				Source: config.CertSourceDev,
+				Dev: config.DevCertificate{
+					ValidFor: "1year",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Missing environment variable is error",
+			cfg: config.TLS{
+				Source:  config.CertSourceEnv,
+				CertKey: "test_CertSource_missing_cert",
+				KeyKey:  "test_CertSource_missing_key",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported source is error",
+			cfg: config.TLS{
+				Source: "vault",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			var crt tls.Certificate
+			src, err := NewCertSource(tt.cfg)
+			if err == nil {
+				crt, err = src.Certificate()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCertSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if len(crt.Certificate) == 0 || crt.PrivateKey == nil {
+				t.Errorf("Certificate() = %v, want the certificate and the private key", crt)
+			}
+			ca, err := src.CA()
+			if err != nil || (ca != nil) != tt.wantCA {
+				t.Errorf("CA() = %v, error = %v, wantCA %v", ca != nil, err, tt.wantCA)
+			}
+			if got := src.Paths(); len(got) != tt.wantPaths {
+				t.Errorf("Paths() = %v, want %d paths", got, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func Test_devSource_Certificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "devsource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := config.TLS{
+		Source: config.CertSourceDev,
+		Dev: config.DevCertificate{
+			Hosts: []string{"dev.example.com"},
+			Dir:   dir,
+		},
+	}
+	load := func() tls.Certificate {
+		src, err := NewCertSource(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		crt, err := src.Certificate()
+		if err != nil {
+			t.Fatalf("Certificate() error = %v", err)
+		}
+		return crt
+	}
+	first := load()
+	if second := load(); !bytes.Equal(first.Certificate[0], second.Certificate[0]) {
+		t.Error("Certificate() generated another certificate of the same configuration")
+	}
+
+	roots, err := NewX509CertPool(filepath.Join(dir, secretCAFile))
+	if err != nil {
+		t.Fatalf("the written CA certificate is invalid: %v", err)
+	}
+	crt, err := tls.LoadX509KeyPair(filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key"))
+	if err != nil || !bytes.Equal(crt.Certificate[0], first.Certificate[0]) {
+		t.Fatalf("the written server certificate is not the served one, error = %v", err)
+	}
+	leaf, err := x509.ParseCertificate(crt.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "dev.example.com", Roots: roots}); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
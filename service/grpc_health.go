@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	// grpcHealthServiceName represents the service name of the standard gRPC health checking protocol
+	grpcHealthServiceName = "grpc.health.v1.Health"
+
+	// grpcHealthSyncInterval represents the interval to update the gRPC serving status from the readiness probe
+	grpcHealthSyncInterval = time.Second
+)
+
+// registerGrpcHealthServer registers the standard gRPC health service to g, and returns it.
+// It returns nil when g already has a health service, since the serving status of it cannot be driven by this package.
+func registerGrpcHealthServer(g *grpc.Server) *grpchealth.Server {
+	if g == nil {
+		return nil
+	}
+	if _, ok := g.GetServiceInfo()[grpcHealthServiceName]; ok {
+		return nil
+	}
+	hs := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(g, hs)
+	return hs
+}
+
+// syncGrpcHealth updates the gRPC serving status from the readiness probe every grpcHealthSyncInterval until the ctx is done.
+func (s *server) syncGrpcHealth(ctx context.Context) {
+	tick := time.NewTicker(grpcHealthSyncInterval)
+	defer tick.Stop()
+	for {
+		s.updateGrpcHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// updateGrpcHealth sets the serving status of the overall server ("") and every registered gRPC service,
+// SERVING when the readiness probe passes, otherwise NOT_SERVING.
+func (s *server) updateGrpcHealth(ctx context.Context) {
+	status := healthpb.HealthCheckResponse_SERVING
+	if s.health.check(ctx, ReadinessProbe).Status != healthStatusOK {
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	s.grpchealth.SetServingStatus("", status)
+	for name := range s.grpcsrv.GetServiceInfo() {
+		if name != grpcHealthServiceName {
+			s.grpchealth.SetServingStatus(name, status)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func Test_server_grpcHealth(t *testing.T) {
+	type test struct {
+		name       string
+		beforeFunc func(*server)
+		want       healthpb.HealthCheckResponse_ServingStatus
+	}
+	tests := []test{
+		{
+			name: "Test SERVING while the readiness probe passes",
+			want: healthpb.HealthCheckResponse_SERVING,
+		},
+		{
+			name: "Test NOT_SERVING when a readiness checker fails",
+			beforeFunc: func(s *server) {
+				s.RegisterHealthChecker(ReadinessProbe, NewHealthChecker("db", func(context.Context) error {
+					return errors.New("connection refused")
+				}))
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "Test NOT_SERVING when the server starts shutting down",
+			beforeFunc: func(s *server) {
+				s.health.startShutdown()
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+		{
+			name: "Test NOT_SERVING after the grpc server shutdown",
+			beforeFunc: func(s *server) {
+				s.grpchealth.Shutdown()
+			},
+			want: healthpb.HealthCheckResponse_NOT_SERVING,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := grpc.NewServer()
+			s := NewServer(config.Server{
+				HealthzPath: "/healthz",
+			}, nil, g, nil, nil, logger.Nop()).(*server)
+			if s.grpchealth == nil {
+				t.Fatal("grpc health service is not registered")
+			}
+
+			l, err := net.Listen("tcp", "localhost:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			go g.Serve(l)
+			defer g.Stop()
+
+			ctx := context.Background()
+			s.updateGrpcHealth(ctx)
+			if tt.beforeFunc != nil {
+				tt.beforeFunc(s)
+			}
+			s.updateGrpcHealth(ctx)
+
+			conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			for _, service := range []string{"", grpcHealthServiceName} {
+				res, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+					Service: service,
+				})
+				if service == grpcHealthServiceName {
+					// the health service itself has no serving status
+					if err == nil {
+						t.Errorf("Check(%q) error = nil, want NotFound", service)
+					}
+					continue
+				}
+				if err != nil {
+					t.Errorf("Check(%q) error = %v", service, err)
+					continue
+				}
+				if res.Status != tt.want {
+					t.Errorf("Check(%q) = %v, want %v", service, res.Status, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func Test_registerGrpcHealthServer(t *testing.T) {
+	g := grpc.NewServer()
+	if registerGrpcHealthServer(g) == nil {
+		t.Error("registerGrpcHealthServer() = nil, want health server")
+	}
+	if registerGrpcHealthServer(g) != nil {
+		t.Error("registerGrpcHealthServer() registered the health service twice")
+	}
+	if registerGrpcHealthServer(nil) != nil {
+		t.Error("registerGrpcHealthServer(nil) != nil")
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/pkg/errors"
+)
+
+// HealthChecker represents a health check of a component, which is registered to the health check server.
+type HealthChecker interface {
+	// Name returns the check name shown in the health check response.
+	Name() string
+
+	// Check returns error when the component is not healthy.
+	Check(ctx context.Context) error
+}
+
+// healthDetailer represents the HealthChecker which shows the additional details of the component in the health check response.
+type healthDetailer interface {
+	// Details returns the JSON serializable details, or nil when there is nothing to show.
+	Details() interface{}
+}
+
+// Probe represents the kind of the K8s probe which a HealthChecker is registered for.
+type Probe int
+
+const (
+	// LivenessProbe represents the liveness probe, the container is restarted when it fails.
+	LivenessProbe Probe = iota
+
+	// ReadinessProbe represents the readiness probe, the pod is removed from the service endpoints when it fails.
+	ReadinessProbe
+)
+
+const (
+	// ApplicationJSON represents a HTTP content type "application/json"
+	ApplicationJSON = "application/json"
+
+	// healthStatusOK represents the status of the healthy check
+	healthStatusOK = "ok"
+
+	// healthStatusUnavailable represents the status of the unhealthy check
+	healthStatusUnavailable = "unavailable"
+)
+
+var (
+	// ErrServerShuttingDown represents an error that the server is shutting down and does not accept new requests
+	ErrServerShuttingDown = errors.New("server is shutting down")
+)
+
+type health struct {
+	log       logger.Logger
+	mu        sync.RWMutex
+	liveness  []HealthChecker
+	readiness []HealthChecker
+
+	// shutdown is set to 1 when the server starts shutting down, it is accessed atomically
+	shutdown int32
+}
+
+// healthResult represents the health check response body.
+type healthResult struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks"`
+}
+
+// checkResult represents the result of a HealthChecker.
+type checkResult struct {
+	Name    string      `json:"name"`
+	Status  string      `json:"status"`
+	Latency string      `json:"latency"`
+	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// healthCheckerFunc is an adapter to use a function as a HealthChecker.
+type healthCheckerFunc struct {
+	name  string
+	check func(context.Context) error
+}
+
+// NewHealthChecker returns a HealthChecker which name is name and runs check.
+func NewHealthChecker(name string, check func(context.Context) error) HealthChecker {
+	return &healthCheckerFunc{
+		name:  name,
+		check: check,
+	}
+}
+
+func (h *healthCheckerFunc) Name() string {
+	return h.name
+}
+
+func (h *healthCheckerFunc) Check(ctx context.Context) error {
+	return h.check(ctx)
+}
+
+// register adds the checkers to the probe.
+func (h *health) register(p Probe, cs ...HealthChecker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch p {
+	case LivenessProbe:
+		h.liveness = append(h.liveness, cs...)
+	case ReadinessProbe:
+		h.readiness = append(h.readiness, cs...)
+	}
+}
+
+// checkers returns the checkers of the probe.
+// The readiness probe always includes the check of the server shutdown state.
+func (h *health) checkers(p Probe) []HealthChecker {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch p {
+	case LivenessProbe:
+		return append([]HealthChecker(nil), h.liveness...)
+	case ReadinessProbe:
+		return append([]HealthChecker{
+			NewHealthChecker("server", h.checkShutdown),
+		}, h.readiness...)
+	}
+	return nil
+}
+
+// startShutdown marks the server is shutting down, so that the readiness probe fails.
+func (h *health) startShutdown() {
+	atomic.StoreInt32(&h.shutdown, 1)
+}
+
+// checkShutdown returns ErrServerShuttingDown after startShutdown is called.
+func (h *health) checkShutdown(context.Context) error {
+	if atomic.LoadInt32(&h.shutdown) != 0 {
+		return ErrServerShuttingDown
+	}
+	return nil
+}
+
+// check runs all checkers of the probe, and returns the result.
+func (h *health) check(ctx context.Context, p Probe) healthResult {
+	cs := h.checkers(p)
+	res := healthResult{
+		Status: healthStatusOK,
+		Checks: make([]checkResult, 0, len(cs)),
+	}
+	for _, c := range cs {
+		start := time.Now()
+		err := c.Check(ctx)
+		cr := checkResult{
+			Name:    c.Name(),
+			Status:  healthStatusOK,
+			Latency: time.Since(start).String(),
+		}
+		if err != nil {
+			cr.Status = healthStatusUnavailable
+			cr.Error = err.Error()
+			res.Status = healthStatusUnavailable
+		}
+		if d, ok := c.(healthDetailer); ok {
+			cr.Details = d.Details()
+		}
+		res.Checks = append(res.Checks, cr)
+	}
+	return res
+}
+
+// handler returns a http.Handler for the health check request of the probe.
+// It responds HTTP Status OK (200) when all checks pass, otherwise HTTP Status Service Unavailable (503), with the JSON body of each check result.
+func (h *health) handler(p Probe) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", fmt.Sprintf("%s, %s", http.MethodGet, http.MethodHead))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		res := h.check(r.Context(), p)
+		code := http.StatusOK
+		if res.Status != healthStatusOK {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set(ContentType, fmt.Sprintf("%s;%s", ApplicationJSON, CharsetUTF8))
+		w.WriteHeader(code)
+		if r.Method == http.MethodHead {
+			return
+		}
+		err := json.NewEncoder(w).Encode(res)
+		if err != nil {
+			h.log.Errorf("failed to write the health check result: %v", err)
+		}
+	})
+}
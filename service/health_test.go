@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/pkg/errors"
+)
+
+func Test_health_handler(t *testing.T) {
+	type test struct {
+		name       string
+		probe      Probe
+		method     string
+		beforeFunc func(*health)
+		wantCode   int
+		wantStatus string
+		wantChecks []string
+	}
+	tests := []test{
+		{
+			name:       "Test liveness probe without checkers",
+			probe:      LivenessProbe,
+			method:     http.MethodGet,
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusOK,
+			wantChecks: []string{},
+		},
+		{
+			name:       "Test readiness probe of serving server",
+			probe:      ReadinessProbe,
+			method:     http.MethodGet,
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusOK,
+			wantChecks: []string{"server"},
+		},
+		{
+			name:   "Test readiness probe fails while shutting down",
+			probe:  ReadinessProbe,
+			method: http.MethodGet,
+			beforeFunc: func(h *health) {
+				h.startShutdown()
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: healthStatusUnavailable,
+			wantChecks: []string{"server"},
+		},
+		{
+			name:   "Test liveness probe does not fail while shutting down",
+			probe:  LivenessProbe,
+			method: http.MethodGet,
+			beforeFunc: func(h *health) {
+				h.startShutdown()
+			},
+			wantCode:   http.StatusOK,
+			wantStatus: healthStatusOK,
+			wantChecks: []string{},
+		},
+		{
+			name:   "Test registered checker failure",
+			probe:  ReadinessProbe,
+			method: http.MethodGet,
+			beforeFunc: func(h *health) {
+				h.register(ReadinessProbe,
+					NewHealthChecker("db", func(context.Context) error {
+						return nil
+					}),
+					NewHealthChecker("cache", func(context.Context) error {
+						return errors.New("connection refused")
+					}),
+				)
+			},
+			wantCode:   http.StatusServiceUnavailable,
+			wantStatus: healthStatusUnavailable,
+			wantChecks: []string{"server", "db", "cache"},
+		},
+		{
+			name:     "Test method not allowed",
+			probe:    LivenessProbe,
+			method:   http.MethodPost,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &health{log: logger.Nop()}
+			if tt.beforeFunc != nil {
+				tt.beforeFunc(h)
+			}
+
+			rw := httptest.NewRecorder()
+			h.handler(tt.probe).ServeHTTP(rw, httptest.NewRequest(tt.method, "/", nil))
+
+			if rw.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", rw.Code, tt.wantCode)
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+			if contentType := rw.Header().Get(ContentType); contentType != fmt.Sprintf("%s;%s", ApplicationJSON, CharsetUTF8) {
+				t.Errorf("content type = %s", contentType)
+			}
+
+			var res healthResult
+			if err := json.NewDecoder(rw.Body).Decode(&res); err != nil {
+				t.Errorf("decode body error: %v", err)
+				return
+			}
+			if res.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", res.Status, tt.wantStatus)
+			}
+			if len(res.Checks) != len(tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", res.Checks, tt.wantChecks)
+				return
+			}
+			for i, c := range res.Checks {
+				if c.Name != tt.wantChecks[i] || c.Latency == "" {
+					t.Errorf("check[%d] = %+v, want %s", i, c, tt.wantChecks[i])
+				}
+			}
+		})
+	}
+}
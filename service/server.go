@@ -6,18 +6,33 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/improbable-eng/grpc-web/go/grpcweb"
-	"github.com/kpango/glg"
+	"github.com/kpango/golang-server-template/accesslog"
 	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/requestid"
 	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
 )
 
 // Server represents server behavior
 type Server interface {
 	ListenAndServe(context.Context) chan []error
+	Reload(config.Server) error
+
+	// RegisterHealthChecker adds the checkers to the probe of the health check server.
+	RegisterHealthChecker(Probe, ...HealthChecker)
+
+	// HandleAdmin registers the admin endpoint to the health check server, which is not exposed to the API clients.
+	// It must be called before ListenAndServe.
+	HandleAdmin(pattern string, h http.Handler)
 }
 
 type server struct {
@@ -27,19 +42,40 @@ type server struct {
 	// Health Check server
 	hcsrv *http.Server
 
+	// hcmux serves the probes, the admin endpoints and the metrics of the health check server
+	hcmux *http.ServeMux
+
+	log logger.Logger
+
 	// grpc server
 	grpcsrv *grpc.Server
 
+	// grpc health server, which serving status is driven by the readiness probe
+	grpchealth *grpchealth.Server
+
 	// grpc web server
 	gwebsrv *http.Server
 
+	// metrics server, it is nil when the metrics are served by the health check server
+	msrv *http.Server
+
 	cfg config.Server
 
-	// ProbeWaitTime
-	pwt time.Duration
+	// ProbeWaitTime, it is accessed atomically
+	pwt int64
+
+	// ShutdownDuration, it is accessed atomically
+	sddur int64
+
+	// certs serves the current TLS certificates of each TLS listener keyed by the listener name, which are swapped when the server is reloaded or the files are changed.
+	// The listener serving plaintext has no entry.
+	certs map[string]CertManager
+
+	// challenges serves the ACME HTTP-01 challenges of the ACME listeners keyed by the port
+	challenges map[int]*acmeChallengeServer
 
-	// ShutdownDuration
-	sddur time.Duration
+	// health represents the liveness and readiness state served by the health check server
+	health health
 }
 
 const (
@@ -51,9 +87,30 @@ const (
 
 	// CharsetUTF8 represents a UTF-8 charset for HTTP response "charset=UTF-8"
 	CharsetUTF8 = "charset=UTF-8"
+
+	// defaultReadinessPath represents the readiness probe path when config.Server.ReadinessPath is empty
+	defaultReadinessPath = "/readiness"
+
+	// defaultMetricsPath represents the metrics path when config.Server.MetricsPath is empty
+	defaultMetricsPath = "/metrics"
+
+	// defaultShutdownDuration represents the shutdown duration when config.Server.ShutdownDuration is empty
+	defaultShutdownDuration = time.Second * 5
+
+	// defaultProbeWaitTime represents the probe wait time when config.Server.ProbeWaitTime is empty
+	defaultProbeWaitTime = time.Second * 3
+
+	// healthCheckRoute represents the route name of the health check server requests in the access log
+	healthCheckRoute = "Health Check"
+
+	// metricsRoute represents the route name of the metrics server requests in the access log
+	metricsRoute = "Metrics"
 )
 
 var (
+	// nextProtos represents the ALPN protocols negotiated by the TLS listeners
+	nextProtos = []string{"h2", "http/1.1"}
+
 	// ErrContextClosed represents a error that the context is closed
 	ErrContextClosed = errors.New("context Closed")
 )
@@ -63,60 +120,185 @@ var (
 // , and set the handler as this function argument "handler".
 //
 // The health check server is a http.Server instance, which the port number is read from "config.Server.HealthzPort"
-// , and the handler is as follow - Handle HTTP GET request and always return HTTP Status OK (200) response.
-func NewServer(cfg config.Server, h http.Handler, g *grpc.Server) Server {
+// , and the handler serves the liveness probe at "config.Server.HealthzPath" and the readiness probe at "config.Server.ReadinessPath".
+// The readiness probe fails as soon as the server starts shutting down, and both probes run the HealthChecker registered by RegisterHealthChecker.
+//
+// The grpc server "g" gets the standard gRPC health service (grpc.health.v1.Health) registered, unless it already has one.
+// Its serving status follows the readiness probe, and turns to NOT_SERVING when the grpc server starts shutting down.
+//
+// When "m" is not nil, the Prometheus metrics are served at "config.Server.MetricsPath" by the metrics server,
+// which the port number is read from "config.Server.MetricsPort", or by the health check server when the port is not defined.
+//
+// When "al" is not nil, the requests of the health check server and the metrics server are access logged.
+// The REST requests and the RPCs are logged by the router and the gRPC interceptors.
+// The errors of the servers are logged by "l".
+//
+// The REST, gRPC and gRPC-Web servers serve TLS when "config.Server.TLS" or the per-listener override in "config.Server.Listeners" is enabled,
+// and plaintext otherwise. The health check server serves TLS only when its own TLS in "config.Server.Listeners" is enabled.
+// The plaintext REST, gRPC-Web and health check servers also serve HTTP/2 without TLS (h2c) when the listener enables it.
+// The certificates are loaded when the servers start, and the expiry of them is reported by the readiness probe.
+// The REST and gRPC-Web servers of the "acme" certificate source obtain the certificates on the handshakes,
+// and the ACME HTTP-01 challenge is served by the challenge server of "http_port" in the ACME configuration.
+func NewServer(cfg config.Server, h http.Handler, g *grpc.Server, m metrics.Metrics, al accesslog.Logger, l logger.Logger) Server {
+	s := new(server)
+	s.health.log = l
+	s.certs = make(map[string]CertManager)
+	for _, name := range config.ListenerNames {
+		if cfg.ListenerTLS(name).Enabled {
+			s.certs[name] = NewCertManager(name, m, l)
+			s.health.register(ReadinessProbe, s.certs[name])
+		}
+	}
+	s.challenges = make(map[int]*acmeChallengeServer)
+	for port, h := range acmeChallengeHandlers(cfg) {
+		s.challenges[port] = newACMEChallengeServer(port, h)
+	}
+
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.RestPort),
-		Handler: h,
+		Handler: plaintextHandler(cfg, config.ListenerREST, h),
 	}
 	srv.SetKeepAlivesEnabled(true)
 
+	rpath := cfg.ReadinessPath
+	if rpath == "" {
+		rpath = defaultReadinessPath
+	}
+
+	hcmux := createHealthCheckServiceMux(cfg.HealthzPath, rpath, &s.health)
 	hcsrv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.HealthzPort),
-		Handler: createHealthCheckServiceMux(cfg.HealthzPath),
+		Handler: plaintextHandler(cfg, config.ListenerHealthCheck, logAccess(al, healthCheckRoute, hcmux)),
 	}
 	hcsrv.SetKeepAlivesEnabled(true)
 
+	if m != nil {
+		mpath := cfg.MetricsPath
+		if mpath == "" {
+			mpath = defaultMetricsPath
+		}
+		if cfg.MetricsPort == 0 {
+			hcmux.Handle(mpath, m.Handler())
+		} else {
+			mmux := http.NewServeMux()
+			mmux.Handle(mpath, m.Handler())
+			s.msrv = &http.Server{
+				Addr:    fmt.Sprintf(":%d", cfg.MetricsPort),
+				Handler: logAccess(al, metricsRoute, mmux),
+			}
+			s.msrv.SetKeepAlivesEnabled(true)
+		}
+	}
+
 	gwebsrv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.GrpcWebPort),
-		Handler: grpcweb.WrapServer(g),
+		Handler: plaintextHandler(cfg, config.ListenerGRPCWeb, grpcWebHandler(grpcweb.WrapServer(g), cfg.RequestIDHeader)),
 	}
 	gwebsrv.SetKeepAlivesEnabled(true)
 
-	dur, err := time.ParseDuration(cfg.ShutdownDuration)
+	dur, err := parseDuration(cfg.ShutdownDuration, defaultShutdownDuration)
+	if err != nil {
+		dur = defaultShutdownDuration
+	}
+
+	pwt, err := parseDuration(cfg.ProbeWaitTime, defaultProbeWaitTime)
+	if err != nil {
+		pwt = defaultProbeWaitTime
+	}
+
+	s.srv = srv
+	s.hcsrv = hcsrv
+	s.hcmux = hcmux
+	s.log = l
+	s.gwebsrv = gwebsrv
+	s.grpcsrv = g
+	s.grpchealth = registerGrpcHealthServer(g)
+	s.cfg = cfg
+	s.pwt = int64(pwt)
+	s.sddur = int64(dur)
+
+	return s
+}
+
+// RegisterHealthChecker adds the checkers to the liveness or readiness probe of the health check server.
+func (s *server) RegisterHealthChecker(p Probe, cs ...HealthChecker) {
+	s.health.register(p, cs...)
+}
+
+func (s *server) HandleAdmin(pattern string, h http.Handler) {
+	s.hcmux.Handle(pattern, h)
+}
+
+// Reload applies the probe wait time, the shutdown duration and the TLS certificates from cfg to the running servers.
+// When any of them cannot be loaded, it returns error and the current configuration is kept.
+// The listener ports and the health check path are not applied until the server restarts.
+func (s *server) Reload(cfg config.Server) error {
+	dur, err := parseDuration(cfg.ShutdownDuration, defaultShutdownDuration)
 	if err != nil {
-		dur = time.Second * 5
+		return errors.Wrap(err, "invalid shutdown_duration")
 	}
 
-	pwt, err := time.ParseDuration(cfg.ProbeWaitTime)
+	pwt, err := parseDuration(cfg.ProbeWaitTime, defaultProbeWaitTime)
 	if err != nil {
-		pwt = time.Second * 3
+		return errors.Wrap(err, "invalid probe_wait_time")
+	}
+
+	// every listener is prepared before any of them is swapped, so that a listener which cannot be loaded keeps the others as they are.
+	// the listener switched between TLS and plaintext keeps serving as it is until the server restarts
+	swaps := make([]func(), 0, len(s.certs))
+	for name, c := range s.certs {
+		if tcfg := cfg.ListenerTLS(name); tcfg.Enabled {
+			swap, err := c.Prepare(tcfg)
+			if err != nil {
+				return errors.Wrapf(err, "failed to reload TLS certificate of the %s listener", name)
+			}
+			swaps = append(swaps, swap)
+		}
+	}
+	for _, swap := range swaps {
+		swap()
 	}
 
-	return &server{
-		srv:     srv,
-		hcsrv:   hcsrv,
-		gwebsrv: gwebsrv,
-		grpcsrv: g,
-		cfg:     cfg,
-		pwt:     pwt,
-		sddur:   dur,
+	// the ACME HTTP-01 challenge server of the new port is not started until the server restarts
+	for port, h := range acmeChallengeHandlers(cfg) {
+		if a, ok := s.challenges[port]; ok {
+			a.handler.Store(h)
+		}
 	}
+
+	atomic.StoreInt64(&s.sddur, int64(dur))
+	atomic.StoreInt64(&s.pwt, int64(pwt))
+
+	return nil
 }
 
 // ListenAndServe returns a error channel, which includes error returned from api server
 // This function start both health check and api server, and the server will close whenever the context receive a Done signal.
-// Whenever the server closed, the api server will shutdown after a defined duration (cfg.ProbeWaitTime), while the health check server will shutdown immediately
+// Whenever the server closed, the readiness probe fails immediately, the api server will shutdown after a defined duration (cfg.ProbeWaitTime),
+// and the health check server will shutdown at last
 func (s *server) ListenAndServe(ctx context.Context) chan []error {
 	echan := make(chan []error, 1)
 	go func() {
 
 		// error channels to keep track server status
-		var sech, gech, gwech, hech <-chan error
+		var sech, gech, gwech, hech, mech <-chan error
 		// error channels to keep track server status
-		var srunning, grunning, gwrunning, hrunning bool
+		var srunning, grunning, gwrunning, hrunning, mrunning bool
 
 		// start server and define error channels to keep track server status
+		// the missing or invalid certificate of any TLS listener fails the startup before any server starts
+		if errs := s.loadCertificates(); len(errs) > 0 {
+			echan <- errs
+			return
+		}
+		// keep the certificates of the TLS listeners up to date
+		for _, c := range s.certs {
+			go c.Watch(ctx)
+		}
+		for _, a := range s.challenges {
+			go s.serveACMEChallenge(ctx, a)
+		}
+
 		if s.srv != nil {
 			sech = s.listenAndServe(s.listenAndServeRestAPI)
 			srunning = true
@@ -125,6 +307,9 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 		if s.grpcsrv != nil {
 			gech = s.listenAndServe(s.listenAndServeGrpcAPI)
 			grunning = true
+			if s.grpchealth != nil {
+				go s.syncGrpcHealth(ctx)
+			}
 		}
 
 		if s.gwebsrv != nil {
@@ -133,10 +318,15 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 		}
 
 		if s.hcsrv != nil {
-			hech = s.listenAndServe(s.hcsrv.ListenAndServe)
+			hech = s.listenAndServe(s.listenAndServeHealthCheck)
 			hrunning = true
 		}
 
+		if s.msrv != nil {
+			mech = s.listenAndServe(s.msrv.ListenAndServe)
+			mrunning = true
+		}
+
 		time.Sleep(time.Second)
 
 		appendErr := func(errs []error, err error) []error {
@@ -152,9 +342,8 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 		for {
 			select {
 			case <-ctx.Done():
-				if hrunning {
-					errs = appendErr(errs, s.hcShutdown(ctx))
-				}
+				// fail the readiness probe first, so that the load balancer stops routing during the probe wait time
+				s.health.startShutdown()
 
 				if srunning {
 					errs = appendErr(errs, s.restShutdown(ctx))
@@ -168,6 +357,14 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 					errs = appendErr(errs, s.grpcWebShutdown(ctx))
 				}
 
+				if hrunning {
+					errs = appendErr(errs, s.hcShutdown(ctx))
+				}
+
+				if mrunning {
+					errs = appendErr(errs, s.metricsShutdown(ctx))
+				}
+
 				echan <- appendErr(errs, ctx.Err())
 				return
 
@@ -175,9 +372,7 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 				if err != nil {
 					errs = appendErr(errs, err)
 				}
-				if hrunning {
-					errs = appendErr(errs, s.hcShutdown(ctx))
-				}
+				s.health.startShutdown()
 
 				if grunning {
 					errs = appendErr(errs, s.grpcShutdown(ctx))
@@ -186,11 +381,21 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 				if gwrunning {
 					errs = appendErr(errs, s.grpcWebShutdown(ctx))
 				}
+
+				if hrunning {
+					errs = appendErr(errs, s.hcShutdown(ctx))
+				}
+
+				if mrunning {
+					errs = appendErr(errs, s.metricsShutdown(ctx))
+				}
+				echan <- errs
 				return
 			case err = <-hech:
 				if err != nil {
 					errs = append(errs, err)
 				}
+				s.health.startShutdown()
 
 				if srunning {
 					errs = appendErr(errs, s.restShutdown(ctx))
@@ -203,14 +408,17 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 				if gwrunning {
 					errs = appendErr(errs, s.grpcWebShutdown(ctx))
 				}
+
+				if mrunning {
+					errs = appendErr(errs, s.metricsShutdown(ctx))
+				}
+				echan <- errs
 				return
 			case err = <-gech:
 				if err != nil {
 					errs = append(errs, err)
 				}
-				if hrunning {
-					errs = appendErr(errs, s.hcShutdown(ctx))
-				}
+				s.health.startShutdown()
 
 				if srunning {
 					errs = appendErr(errs, s.restShutdown(ctx))
@@ -219,15 +427,45 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 				if gwrunning {
 					errs = appendErr(errs, s.grpcWebShutdown(ctx))
 				}
+
+				if hrunning {
+					errs = appendErr(errs, s.hcShutdown(ctx))
+				}
+
+				if mrunning {
+					errs = appendErr(errs, s.metricsShutdown(ctx))
+				}
+				echan <- errs
 				return
 			case err = <-gwech:
 				if err != nil {
 					errs = append(errs, err)
 				}
+				s.health.startShutdown()
+
+				if srunning {
+					errs = appendErr(errs, s.restShutdown(ctx))
+				}
+
+				if grunning {
+					errs = appendErr(errs, s.grpcShutdown(ctx))
+				}
+
 				if hrunning {
 					errs = appendErr(errs, s.hcShutdown(ctx))
 				}
 
+				if mrunning {
+					errs = appendErr(errs, s.metricsShutdown(ctx))
+				}
+				echan <- errs
+				return
+			case err = <-mech:
+				if err != nil {
+					errs = append(errs, err)
+				}
+				s.health.startShutdown()
+
 				if srunning {
 					errs = appendErr(errs, s.restShutdown(ctx))
 				}
@@ -235,6 +473,15 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 				if grunning {
 					errs = appendErr(errs, s.grpcShutdown(ctx))
 				}
+
+				if gwrunning {
+					errs = appendErr(errs, s.grpcWebShutdown(ctx))
+				}
+
+				if hrunning {
+					errs = appendErr(errs, s.hcShutdown(ctx))
+				}
+				echan <- errs
 				return
 			}
 		}
@@ -243,57 +490,101 @@ func (s *server) ListenAndServe(ctx context.Context) chan []error {
 	return echan
 }
 
+// probeWaitTime returns the current probe wait time
+func (s *server) probeWaitTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.pwt))
+}
+
+// shutdownDuration returns the current shutdown duration
+func (s *server) shutdownDuration() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.sddur))
+}
+
 // hcShutdown returns error if health check server shutdown unsuccessful
 func (s *server) hcShutdown(ctx context.Context) error {
-	hctx, hcancel := context.WithTimeout(ctx, s.sddur)
+	hctx, hcancel := context.WithTimeout(ctx, s.shutdownDuration())
 	defer hcancel()
 	s.hcsrv.SetKeepAlivesEnabled(false)
 	return s.hcsrv.Shutdown(hctx)
 }
 
+// metricsShutdown returns error if metrics server shutdown unsuccessful
+func (s *server) metricsShutdown(ctx context.Context) error {
+	mctx, mcancel := context.WithTimeout(ctx, s.shutdownDuration())
+	defer mcancel()
+	s.msrv.SetKeepAlivesEnabled(false)
+	return s.msrv.Shutdown(mctx)
+}
+
 // restShutdown returns error if rest api server shutdown unsuccessful
 func (s *server) restShutdown(ctx context.Context) error {
-	time.Sleep(s.pwt)
-	sctx, scancel := context.WithTimeout(ctx, s.sddur)
+	time.Sleep(s.probeWaitTime())
+	sctx, scancel := context.WithTimeout(ctx, s.shutdownDuration())
 	defer scancel()
 	s.srv.SetKeepAlivesEnabled(false)
 	return s.srv.Shutdown(sctx)
 }
 
 // grpcShutdown returns error if grpc api server shutdown unsuccessful
+// The gRPC health service reports NOT_SERVING for all services before the probe wait time.
 func (s *server) grpcShutdown(ctx context.Context) error {
-	time.Sleep(s.pwt)
+	if s.grpchealth != nil {
+		s.grpchealth.Shutdown()
+	}
+	time.Sleep(s.probeWaitTime())
 	s.grpcsrv.GracefulStop()
 	return nil
 }
 
 // grpcWebShutdown returns error if grpc web api server shutdown unsuccessful
 func (s *server) grpcWebShutdown(ctx context.Context) error {
-	time.Sleep(s.pwt)
-	sctx, scancel := context.WithTimeout(ctx, s.sddur)
+	time.Sleep(s.probeWaitTime())
+	sctx, scancel := context.WithTimeout(ctx, s.shutdownDuration())
 	defer scancel()
 	s.gwebsrv.SetKeepAlivesEnabled(false)
 	return s.gwebsrv.Shutdown(sctx)
 }
 
 // createHealthCheckServiceMux return a *http.ServeMux object
-// The function will register the health check server handler for given pattern, and return
-func createHealthCheckServiceMux(pattern string) *http.ServeMux {
+// The function will register the liveness and readiness probe handler for given patterns, and return
+func createHealthCheckServiceMux(liveness, readiness string, h *health) *http.ServeMux {
 	mux := http.NewServeMux()
-	mux.HandleFunc(pattern, handleHealthCheckRequest)
+	mux.Handle(liveness, h.handler(LivenessProbe))
+	mux.Handle(readiness, h.handler(ReadinessProbe))
 	return mux
 }
 
-// handleHealthCheckRequest is a handler function for and health check request, which always a HTTP Status OK (200) result
-func handleHealthCheckRequest(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodGet {
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set(ContentType, fmt.Sprintf("%s;%s", TextPlain, CharsetUTF8))
-		_, err := fmt.Fprint(w, http.StatusText(http.StatusOK))
-		if err != nil {
-			glg.Fatal(err)
-		}
+// grpcWebHandler returns a http.Handler which marks the request context as gRPC-Web,
+// so that the gRPC interceptors can tell it from the native gRPC request.
+// The request ID of the header is accepted or generated, and echoed as the HTTP response header.
+func grpcWebHandler(h http.Handler, header string) http.Handler {
+	return requestid.Middleware(header)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(metrics.WithProtocol(r.Context(), metrics.ProtocolGRPCWeb)))
+	}))
+}
+
+// plaintextHandler returns h, which also serves HTTP/2 without TLS when the listener serves plaintext and enables h2c.
+func plaintextHandler(cfg config.Server, listener string, h http.Handler) http.Handler {
+	if cfg.ListenerTLS(listener).Enabled || !cfg.Listener(listener).H2C {
+		return h
 	}
+	return h2c.NewHandler(h, new(http2.Server))
+}
+
+// logAccess returns h which requests are logged by al labelled by the route name, or h itself when al is nil.
+func logAccess(al accesslog.Logger, route string, h http.Handler) http.Handler {
+	if al == nil {
+		return h
+	}
+	return al.InstrumentHandler(route, h)
+}
+
+// parseDuration returns the parsed duration of val, or def when val is empty.
+func parseDuration(val string, def time.Duration) (time.Duration, error) {
+	if val == "" {
+		return def, nil
+	}
+	return time.ParseDuration(val)
 }
 
 func (s *server) listenAndServe(starter func() error) <-chan error {
@@ -305,39 +596,53 @@ func (s *server) listenAndServe(starter func() error) <-chan error {
 	return ech
 }
 
-// listenAndServeGrpcAPI return any error occurred when start a HTTPS server, including any error when loading TLS certificate
+// loadCertificates loads the certificates of every TLS listener, and returns the errors of the listeners which cannot load them.
+func (s *server) loadCertificates() []error {
+	var errs []error
+	for name, c := range s.certs {
+		if err := c.Reload(s.cfg.ListenerTLS(name)); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to load the TLS certificate of the %s listener", name))
+		}
+	}
+	return errs
+}
+
+// listenAndServeGrpcAPI return any error occurred when start a gRPC server, which serves TLS when the listener has the certificates
 func (s *server) listenAndServeGrpcAPI() error {
-	cfg, err := NewTLSConfig(s.cfg.TLS)
 	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.cfg.GrpcPort))
-	if err == nil && cfg != nil {
-		l = tls.NewListener(l, cfg)
-	}
 	if err != nil {
-		glg.Error(err)
+		s.log.Errorf("failed to start the gRPC server: %v", err)
+		return err
+	}
+	if c, ok := s.certs[config.ListenerGRPC]; ok {
+		l = tls.NewListener(l, c.TLSConfig())
 	}
 	return s.grpcsrv.Serve(l)
 }
 
-// listenAndServeGrpcWebAPI return any error occurred when start a HTTPS server, including any error when loading TLS certificate
+// listenAndServeGrpcWebAPI return any error occurred when start a gRPC-Web server, which serves TLS when the listener has the certificates
 func (s *server) listenAndServeGrpcWebAPI() error {
-	cfg, err := NewTLSConfig(s.cfg.TLS)
-	if err == nil && cfg != nil {
-		s.gwebsrv.TLSConfig = cfg
+	if c, ok := s.certs[config.ListenerGRPCWeb]; ok {
+		s.gwebsrv.TLSConfig = c.TLSConfig()
+		return s.gwebsrv.ListenAndServeTLS("", "")
 	}
-	if err != nil {
-		glg.Error(err)
+	return s.gwebsrv.ListenAndServe()
+}
+
+// listenAndServeHealthCheck return any error occurred when start a health check server, which serves TLS when the listener has the certificates
+func (s *server) listenAndServeHealthCheck() error {
+	if c, ok := s.certs[config.ListenerHealthCheck]; ok {
+		s.hcsrv.TLSConfig = c.TLSConfig()
+		return s.hcsrv.ListenAndServeTLS("", "")
 	}
-	return s.gwebsrv.ListenAndServeTLS("", "")
+	return s.hcsrv.ListenAndServe()
 }
 
-// listenAndServeRestAPI return any error occurred when start a HTTPS server, including any error when loading TLS certificate
+// listenAndServeRestAPI return any error occurred when start a REST server, which serves TLS when the listener has the certificates
 func (s *server) listenAndServeRestAPI() error {
-	cfg, err := NewTLSConfig(s.cfg.TLS)
-	if err == nil && cfg != nil {
-		s.srv.TLSConfig = cfg
-	}
-	if err != nil {
-		glg.Error(err)
+	if c, ok := s.certs[config.ListenerREST]; ok {
+		s.srv.TLSConfig = c.TLSConfig()
+		return s.srv.ListenAndServeTLS("", "")
 	}
-	return s.srv.ListenAndServeTLS("", "")
+	return s.srv.ListenAndServe()
 }
@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,6 +13,10 @@ import (
 	"time"
 
 	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/pkg/errors"
+	"golang.org/x/net/http2"
 	"google.golang.org/grpc"
 )
 
@@ -18,6 +25,11 @@ func TestNewServer(t *testing.T) {
 		cfg config.Server
 		h   http.Handler
 		g   *grpc.Server
+		m   metrics.Metrics
+	}
+	m, err := metrics.New()
+	if err != nil {
+		t.Fatal(err)
 	}
 	tests := []struct {
 		name      string
@@ -74,12 +86,62 @@ func TestNewServer(t *testing.T) {
 				return nil
 			},
 		},
+		{
+			name: "Check metrics served by health check server",
+			args: args{
+				cfg: config.Server{
+					HealthzPath: "/healthz",
+					HealthzPort: 8080,
+				},
+				m: m,
+			},
+			want: &server{},
+			checkFunc: func(got, want Server) error {
+				if got.(*server).msrv != nil {
+					return fmt.Errorf("Metrics server is created	got: %s", got.(*server).msrv.Addr)
+				}
+				rec := httptest.NewRecorder()
+				got.(*server).hcsrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, defaultMetricsPath, nil))
+				if rec.Code != http.StatusOK {
+					return fmt.Errorf("Metrics status code not equals\tgot: %d\twant: %d", rec.Code, http.StatusOK)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Check metrics address",
+			args: args{
+				cfg: config.Server{
+					HealthzPath: "/healthz",
+					HealthzPort: 8080,
+					MetricsPort: 9090,
+					MetricsPath: "/prometheus",
+				},
+				m: m,
+			},
+			want: &server{
+				msrv: &http.Server{
+					Addr: fmt.Sprintf(":%d", 9090),
+				},
+			},
+			checkFunc: func(got, want Server) error {
+				if got.(*server).msrv == nil || got.(*server).msrv.Addr != want.(*server).msrv.Addr {
+					return fmt.Errorf("Metrics Addr not equals\twant: %s", want.(*server).msrv.Addr)
+				}
+				rec := httptest.NewRecorder()
+				got.(*server).msrv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/prometheus", nil))
+				if rec.Code != http.StatusOK {
+					return fmt.Errorf("Metrics status code not equals\tgot: %d\twant: %d", rec.Code, http.StatusOK)
+				}
+				return nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := NewServer(tt.args.cfg, tt.args.h, tt.args.g)
+			got := NewServer(tt.args.cfg, tt.args.h, tt.args.g, tt.args.m, nil, logger.Nop())
 			if err := tt.checkFunc(got, tt.want); err != nil {
-				t.Errorf("NewServer() = %v, want %v", got, tt.want)
+				t.Errorf("NewServer() = %v, want %v: %v", got, tt.want, err)
 			}
 		})
 	}
@@ -109,14 +171,25 @@ func Test_server_ListenAndServe(t *testing.T) {
 					Handler: func() http.Handler {
 						return nil
 					}(),
+					Addr: "localhost:0",
 				},
 				hcsrv: &http.Server{
 					Handler: func() http.Handler {
 						return nil
 					}(),
+					Addr: "localhost:0",
+				},
+				cfg: config.Server{
+					TLS: config.TLS{
+						Enabled: true,
+						CertKey: "test_ListenAndServe_cert",
+						KeyKey:  "test_ListenAndServe_key",
+					},
 				},
 			},
 			args: func() args {
+				os.Setenv("test_ListenAndServe_cert", "./assets/dummyServer.crt")
+				os.Setenv("test_ListenAndServe_key", "./assets/dummyServer.key")
 				ctx, cancelFunc := context.WithCancel(context.Background())
 				return args{
 					ctx:        ctx,
@@ -145,6 +218,11 @@ func Test_server_ListenAndServe(t *testing.T) {
 				srv:   tt.fields.srv,
 				hcsrv: tt.fields.hcsrv,
 				cfg:   tt.fields.cfg,
+				log:   logger.Nop(),
+				certs: map[string]CertManager{
+					config.ListenerREST: NewCertManager(config.ListenerREST, nil, logger.Nop()),
+					config.ListenerGRPC: NewCertManager(config.ListenerGRPC, nil, logger.Nop()),
+				},
 			}
 
 			e := s.ListenAndServe(tt.args.ctx)
@@ -157,7 +235,8 @@ func Test_server_ListenAndServe(t *testing.T) {
 
 func Test_server_createHealthCheckServiceMux(t *testing.T) {
 	type args struct {
-		pattern string
+		liveness  string
+		readiness string
 	}
 	type test struct {
 		name       string
@@ -165,7 +244,7 @@ func Test_server_createHealthCheckServiceMux(t *testing.T) {
 		beforeFunc func() error
 		checkFunc  func(*http.ServeMux) error
 		afterFunc  func() error
-		want       http.ServeMux
+		want       *http.ServeMux
 		wantErr    error
 	}
 	tests := []test{
@@ -173,12 +252,18 @@ func Test_server_createHealthCheckServiceMux(t *testing.T) {
 			return test{
 				name: "Test create server mux",
 				args: args{
-					pattern: ":8080",
+					liveness:  "/healthz",
+					readiness: "/readiness",
 				},
 				checkFunc: func(got *http.ServeMux) error {
 					if got == nil {
 						return fmt.Errorf("serveMux is empty")
 					}
+					for _, path := range []string{"/healthz", "/readiness"} {
+						if _, pattern := got.Handler(httptest.NewRequest(http.MethodGet, path, nil)); pattern != path {
+							return fmt.Errorf("handler for %s is not registered", path)
+						}
+					}
 					return nil
 				},
 			}
@@ -203,7 +288,7 @@ func Test_server_createHealthCheckServiceMux(t *testing.T) {
 				}
 			}
 
-			got := createHealthCheckServiceMux(tt.args.pattern)
+			got := createHealthCheckServiceMux(tt.args.liveness, tt.args.readiness, &health{log: logger.Nop()})
 			if err := tt.checkFunc(got); err != nil {
 				t.Errorf("server.listenAndServeAPI() Error = %v", err)
 			}
@@ -211,69 +296,6 @@ func Test_server_createHealthCheckServiceMux(t *testing.T) {
 	}
 }
 
-func Test_server_handleHealthCheckRequest(t *testing.T) {
-	type args struct {
-		rw http.ResponseWriter
-		r  *http.Request
-	}
-	type test struct {
-		name       string
-		args       args
-		beforeFunc func() error
-		checkFunc  func() error
-		afterFunc  func() error
-		wantErr    error
-	}
-	tests := []test{
-		func() test {
-			rw := httptest.NewRecorder()
-
-			return test{
-				name: "Test handle HTTP GET request health check request",
-				args: args{
-					rw: rw,
-					r:  httptest.NewRequest(http.MethodGet, "/", nil),
-				},
-				checkFunc: func() error {
-					result := rw.Result()
-					if header := result.StatusCode; header != http.StatusOK {
-						return fmt.Errorf("Header is not correct, got: %v", header)
-					}
-					if contentType := rw.Header().Get("Content-Type"); contentType != "text/plain;charset=UTF-8" {
-						return fmt.Errorf("Content type is not correct, got: %v", contentType)
-					}
-					return nil
-				},
-			}
-		}(),
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			if tt.afterFunc != nil {
-				defer func() {
-					if err := tt.afterFunc(); err != nil {
-						t.Errorf("%v", err)
-						return
-					}
-				}()
-			}
-
-			if tt.beforeFunc != nil {
-				err := tt.beforeFunc()
-				if err != nil {
-					t.Errorf("beforeFunc error, error: %v", err)
-					return
-				}
-			}
-
-			handleHealthCheckRequest(tt.args.rw, tt.args.r)
-			if err := tt.checkFunc(); err != nil {
-				t.Errorf("error: %v", err)
-			}
-		})
-	}
-}
-
 func Test_server_listenAndServeAPI(t *testing.T) {
 	type fields struct {
 		srv   *http.Server
@@ -372,9 +394,282 @@ func Test_server_listenAndServeAPI(t *testing.T) {
 				srv:   tt.fields.srv,
 				hcsrv: tt.fields.hcsrv,
 				cfg:   tt.fields.cfg,
+				log:   logger.Nop(),
+				certs: map[string]CertManager{
+					config.ListenerREST: NewCertManager(config.ListenerREST, nil, logger.Nop()),
+					config.ListenerGRPC: NewCertManager(config.ListenerGRPC, nil, logger.Nop()),
+				},
 			}, tt.want); err != nil {
 				t.Errorf("server.listenAndServeAPI() Error = %v", err)
 			}
 		})
 	}
 }
+
+func Test_server_ListenAndServe_certificateError(t *testing.T) {
+	cfg := config.Server{
+		HealthzPath: "/healthz",
+		TLS: config.TLS{
+			Enabled: true,
+			CertKey: "test_ListenAndServe_missing_cert",
+			KeyKey:  "test_ListenAndServe_missing_key",
+		},
+	}
+	s := NewServer(cfg, nil, grpc.NewServer(), nil, nil, logger.Nop()).(*server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	select {
+	case errs := <-s.ListenAndServe(ctx):
+		if len(errs) != 3 {
+			t.Fatalf("ListenAndServe() errors = %v, want the errors of 3 listeners", errs)
+		}
+		for _, err := range errs {
+			if errors.Cause(err) != ErrTLSCertOrKeyNotFound {
+				t.Errorf("ListenAndServe() error = %v, want %v", err, ErrTLSCertOrKeyNotFound)
+			}
+		}
+	case <-time.After(time.Second * 3):
+		t.Error("ListenAndServe() started the servers without the certificate")
+	}
+}
+
+func Test_plaintextHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	})
+	tests := []struct {
+		name string
+		cfg  config.Server
+		want string
+	}{
+		{
+			name: "h2c serves HTTP/2 without TLS",
+			cfg: config.Server{
+				Listeners: config.Listeners{
+					REST: config.Listener{H2C: true},
+				},
+			},
+			want: "HTTP/2.0",
+		},
+		{
+			name: "h2c is not served when TLS is enabled",
+			cfg: config.Server{
+				TLS: config.TLS{Enabled: true},
+				Listeners: config.Listeners{
+					REST: config.Listener{H2C: true},
+				},
+			},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(plaintextHandler(tt.cfg, config.ListenerREST, h))
+			defer srv.Close()
+
+			// the HTTP/2 request with prior knowledge, which fails unless h2c is served
+			c := &http.Client{
+				Transport: &http2.Transport{
+					AllowHTTP: true,
+					DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+						return net.Dial(network, addr)
+					},
+				},
+			}
+			var got string
+			if res, err := c.Get(srv.URL); err == nil {
+				b, _ := ioutil.ReadAll(res.Body)
+				res.Body.Close()
+				got = string(b)
+			}
+			if got != tt.want {
+				t.Errorf("plaintextHandler() protocol = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_server_Reload(t *testing.T) {
+	type test struct {
+		name       string
+		cfg        config.Server
+		beforeFunc func() error
+		afterFunc  func() error
+		checkFunc  func(*server) error
+		wantErr    bool
+	}
+	certKey := "test_Reload_cert"
+	keyKey := "test_Reload_key"
+	tests := []test{
+		{
+			name: "Test reload durations and certificates",
+			cfg: config.Server{
+				ShutdownDuration: "10s",
+				ProbeWaitTime:    "7s",
+				TLS: config.TLS{
+					Enabled: true,
+					CertKey: certKey,
+					KeyKey:  keyKey,
+				},
+			},
+			beforeFunc: func() error {
+				err := os.Setenv(certKey, "./assets/dummyServer.crt")
+				if err != nil {
+					return err
+				}
+				return os.Setenv(keyKey, "./assets/dummyServer.key")
+			},
+			afterFunc: func() error {
+				os.Unsetenv(certKey)
+				return os.Unsetenv(keyKey)
+			},
+			checkFunc: func(s *server) error {
+				if got := s.shutdownDuration(); got != time.Second*10 {
+					return fmt.Errorf("shutdown duration not reloaded, got: %v", got)
+				}
+				if got := s.probeWaitTime(); got != time.Second*7 {
+					return fmt.Errorf("probe wait time not reloaded, got: %v", got)
+				}
+				if crt, err := s.certs[config.ListenerREST].GetCertificate(nil); err != nil || crt.Leaf.Subject.CommonName != "localhost" {
+					return fmt.Errorf("certificate not reloaded: %v", err)
+				}
+				return nil
+			},
+		},
+		{
+			name: "Test invalid duration keeps the current configuration",
+			cfg: config.Server{
+				ShutdownDuration: "10sec",
+				ProbeWaitTime:    "7s",
+			},
+			checkFunc: func(s *server) error {
+				if got := s.shutdownDuration(); got != time.Second*5 {
+					return fmt.Errorf("shutdown duration changed, got: %v", got)
+				}
+				if got := s.probeWaitTime(); got != time.Second*3 {
+					return fmt.Errorf("probe wait time changed, got: %v", got)
+				}
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test missing certificate keeps the current configuration",
+			cfg: config.Server{
+				ShutdownDuration: "10s",
+				ProbeWaitTime:    "7s",
+				TLS: config.TLS{
+					Enabled: true,
+					CertKey: certKey,
+					KeyKey:  keyKey,
+				},
+			},
+			checkFunc: func(s *server) error {
+				if got := s.shutdownDuration(); got != time.Second*5 {
+					return fmt.Errorf("shutdown duration changed, got: %v", got)
+				}
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test missing certificate of a listener keeps the certificates of the other listeners",
+			cfg: config.Server{
+				TLS: config.TLS{
+					Enabled: true,
+					CertKey: certKey,
+					KeyKey:  keyKey,
+				},
+				Listeners: config.Listeners{
+					GRPC: config.Listener{
+						TLS: &config.TLS{
+							Enabled: true,
+							CertKey: "test_Reload_missing_cert",
+							KeyKey:  keyKey,
+						},
+					},
+				},
+			},
+			beforeFunc: func() error {
+				err := os.Setenv(certKey, "./assets/dummyServer.crt")
+				if err != nil {
+					return err
+				}
+				return os.Setenv(keyKey, "./assets/dummyServer.key")
+			},
+			afterFunc: func() error {
+				os.Unsetenv(certKey)
+				return os.Unsetenv(keyKey)
+			},
+			checkFunc: func(s *server) error {
+				if _, err := s.certs[config.ListenerREST].GetCertificate(nil); err != ErrCertificateNotLoaded {
+					return fmt.Errorf("certificate of the rest listener swapped, error: %v", err)
+				}
+				return nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "Test reload ACME configuration swaps the HTTP-01 challenge handler",
+			cfg: config.Server{
+				TLS: config.TLS{
+					Enabled: true,
+					Source:  config.CertSourceACME,
+					ACME: config.ACME{
+						Domains:  []string{"acme.example.com"},
+						CacheDir: os.TempDir(),
+						HTTPPort: 8084,
+					},
+				},
+			},
+			checkFunc: func(s *server) error {
+				req := httptest.NewRequest(http.MethodGet, "http://other.example.com/.well-known/acme-challenge/token", nil)
+				rec := httptest.NewRecorder()
+				s.challenges[8084].ServeHTTP(rec, req)
+				// the host policy of the ACME manager rejects the domain which is not configured
+				if rec.Code != http.StatusForbidden {
+					return fmt.Errorf("challenge handler not reloaded, got status: %d", rec.Code)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.afterFunc != nil {
+				defer func() {
+					if err := tt.afterFunc(); err != nil {
+						t.Errorf("%v", err)
+					}
+				}()
+			}
+
+			if tt.beforeFunc != nil {
+				if err := tt.beforeFunc(); err != nil {
+					t.Errorf("beforeFunc error, error: %v", err)
+					return
+				}
+			}
+
+			s := &server{
+				pwt:   int64(time.Second * 3),
+				sddur: int64(time.Second * 5),
+				certs: map[string]CertManager{
+					config.ListenerREST: NewCertManager(config.ListenerREST, nil, logger.Nop()),
+					config.ListenerGRPC: NewCertManager(config.ListenerGRPC, nil, logger.Nop()),
+				},
+				challenges: map[int]*acmeChallengeServer{
+					8084: newACMEChallengeServer(8084, http.NotFoundHandler()),
+				},
+			}
+			if err := s.Reload(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("server.Reload() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err := tt.checkFunc(s); err != nil {
+				t.Errorf("server.Reload() Error = %v", err)
+			}
+		})
+	}
+}
@@ -4,21 +4,57 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"io/ioutil"
-	"os"
 
 	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/identity"
 	"github.com/pkg/errors"
 )
 
 var (
 	// ErrTLSCertOrKeyNotFound is error variable, it's replesents tls cert or key not found error
 	ErrTLSCertOrKeyNotFound = errors.New("Cert/Key path not found")
+
+	// ErrClientCANotFound represents the error of the client auth mode which verifies the client certificate without the CA certificate
+	ErrClientCANotFound = errors.New("CA path not found, it is required to verify the client certificate")
+
+	// ErrClientNotAllowed represents the error of the verified client certificate which matches none of the allowed patterns
+	ErrClientNotAllowed = errors.New("client certificate is not allowed")
 )
 
+// clientAuthTypes represents the tls.ClientAuthType of each config.TLS.ClientAuth.
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	config.ClientAuthNone:             tls.NoClientCert,
+	config.ClientAuthRequest:          tls.RequestClientCert,
+	config.ClientAuthRequireAny:       tls.RequireAnyClientCert,
+	config.ClientAuthVerifyIfGiven:    tls.VerifyClientCertIfGiven,
+	config.ClientAuthRequireAndVerify: tls.RequireAndVerifyClientCert,
+}
+
+// tlsVersions represents the TLS version of each config.TLSVersions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// curveIDs represents the tls.CurveID of each config.TLSCurves.
+var curveIDs = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
 // NewTLSConfig returns a *tls.Config struct or error
 // This function read TLS configuration and initialize *tls.Config struct.
 // This function initialize TLS configuration, for example the CA certificate and key to start TLS server.
-// Server and CA Certificate, and private key will read from a file from the file path definied in environment variable.
+// Server and CA Certificate, and private key will read from the CertSource of cfg.Source, which is the file path definied in environment variable by default.
+// The additional certificates of cfg.Certificates are selected by the SNI hostname, and the first certificate is served when none of them matches.
+// The certificates of the "acme" source are obtained by tls.Config.GetCertificate on the handshakes, which also serves the TLS-ALPN-01 challenge negotiated by tls.Config.NextProtos.
+// The TLS versions, the cipher suites and the curve preferences are the secure defaults unless they are defined by cfg.
+// The client certificate is requested and verified by the CA certificate as defined by cfg.ClientAuth,
+// and the verified certificate is rejected unless it matches any of cfg.AllowedClientSubjects and cfg.AllowedClientSANs.
 func NewTLSConfig(cfg config.TLS) (*tls.Config, error) {
 	t := &tls.Config{
 		MinVersion: tls.VersionTLS12,
@@ -29,52 +65,28 @@ func NewTLSConfig(cfg config.TLS) (*tls.Config, error) {
 			tls.X25519,
 		},
 		SessionTicketsDisabled: true,
-		// PreferServerCipherSuites: true,
-		// CipherSuites: []uint16{
-		// tls.TLS_RSA_WITH_RC4_128_SHA,
-		// tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-		// tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-		// tls.TLS_RSA_WITH_AES_128_CBC_SHA256,
-		// tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
-		// tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
-		// tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
-		// tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-		// tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-		// tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA,
-		// tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-		// tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-		// tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-		// tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
-		// tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256,
-		// tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-		// tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
-		// tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
-		// tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384, // Maybe this is work on TLS 1.2
-		// tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA, // TLS1.3 Feature
-		// tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA, // TLS1.3 Feature
-		// tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305, // Go 1.8 only
-		// tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305, // Go 1.8 only
-		// },
-		ClientAuth: tls.NoClientCert,
-	}
-
-	cert := os.Getenv(cfg.CertKey)
-	key := os.Getenv(cfg.KeyKey)
-	ca := os.Getenv(cfg.CAKey)
-
-	if cert == "" || key == "" {
-		return nil, ErrTLSCertOrKeyNotFound
-	}
-
-	crt, err := tls.LoadX509KeyPair(cert, key)
+		ClientAuth:             tls.NoClientCert,
+	}
+	if err := applyProtocols(t, cfg); err != nil {
+		return nil, err
+	}
+
+	src, err := NewCertSource(cfg)
 	if err != nil {
 		return nil, err
 	}
-	t.Certificates = make([]tls.Certificate, 1)
-	t.Certificates[0] = crt
+	if cfg.Source == config.CertSourceACME {
+		applyACMETLSConfig(t, cfg.ACME)
+	} else if err := loadTLSCertificates(t, cfg, src); err != nil {
+		return nil, err
+	}
 
-	if ca != "" {
-		pool, err := NewX509CertPool(ca)
+	ca, err := src.CA()
+	if err != nil {
+		return nil, err
+	}
+	if ca != nil {
+		pool, err := newX509CertPool(ca)
 		if err != nil {
 			return nil, err
 		}
@@ -82,23 +94,137 @@ func NewTLSConfig(cfg config.TLS) (*tls.Config, error) {
 		t.ClientAuth = tls.RequireAndVerifyClientCert
 	}
 
+	if cfg.ClientAuth != "" {
+		auth, ok := clientAuthTypes[cfg.ClientAuth]
+		if !ok {
+			return nil, errors.Errorf("unsupported client auth mode %q", cfg.ClientAuth)
+		}
+		if (auth == tls.VerifyClientCertIfGiven || auth == tls.RequireAndVerifyClientCert) && t.ClientCAs == nil {
+			return nil, ErrClientCANotFound
+		}
+		t.ClientAuth = auth
+	}
+
+	if len(cfg.AllowedClientSubjects)+len(cfg.AllowedClientSANs) > 0 {
+		t.VerifyPeerCertificate = verifyClient(cfg.AllowedClientSubjects, cfg.AllowedClientSANs)
+	}
+
 	t.BuildNameToCertificate()
 	return t, nil
 }
 
+// loadTLSCertificates loads the certificate of src and the additional certificates of cfg.Certificates to t.
+func loadTLSCertificates(t *tls.Config, cfg config.TLS, src CertSource) error {
+	crt, err := src.Certificate()
+	if err != nil {
+		return err
+	}
+	t.Certificates = make([]tls.Certificate, 1, len(cfg.Certificates)+1)
+	t.Certificates[0] = crt
+
+	for _, c := range cfg.Certificates {
+		name := c.CertKey + c.SecretDir
+		s, err := NewCertSource(sniConfig(cfg, c))
+		if err != nil {
+			return errors.Wrapf(err, "certificate %s", name)
+		}
+		crt, err := s.Certificate()
+		if err != nil {
+			return errors.Wrapf(err, "certificate %s", name)
+		}
+		t.Certificates = append(t.Certificates, crt)
+	}
+	return nil
+}
+
+// applyProtocols applies the TLS versions, the cipher suites and the curve preferences of cfg to t, the defaults of t are kept unless they are defined.
+func applyProtocols(t *tls.Config, cfg config.TLS) error {
+	if cfg.MinVersion != "" {
+		v, ok := tlsVersions[cfg.MinVersion]
+		if !ok {
+			return errors.Errorf("unsupported TLS version %q", cfg.MinVersion)
+		}
+		t.MinVersion = v
+	}
+	if cfg.MaxVersion != "" {
+		v, ok := tlsVersions[cfg.MaxVersion]
+		if !ok {
+			return errors.Errorf("unsupported TLS version %q", cfg.MaxVersion)
+		}
+		t.MaxVersion = v
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		ids := make(map[string]uint16, len(tls.CipherSuites()))
+		for _, c := range tls.CipherSuites() {
+			ids[c.Name] = c.ID
+		}
+		t.CipherSuites = make([]uint16, 0, len(cfg.CipherSuites))
+		for _, name := range cfg.CipherSuites {
+			id, ok := ids[name]
+			if !ok {
+				return errors.Errorf("unsupported cipher suite %q", name)
+			}
+			t.CipherSuites = append(t.CipherSuites, id)
+		}
+	}
+
+	if len(cfg.CurvePreferences) > 0 {
+		t.CurvePreferences = make([]tls.CurveID, 0, len(cfg.CurvePreferences))
+		for _, name := range cfg.CurvePreferences {
+			id, ok := curveIDs[name]
+			if !ok {
+				return errors.Errorf("unsupported curve %q", name)
+			}
+			t.CurvePreferences = append(t.CurvePreferences, id)
+		}
+	}
+	return nil
+}
+
+// verifyClient returns the tls.Config.VerifyPeerCertificate which rejects the verified client certificate
+// unless its subject matches any of the subjects patterns, or its subject alternative names match any of the sans patterns.
+// It accepts the client which sent no certificate, since the certificate is required by tls.Config.ClientAuth if needed.
+func verifyClient(subjects, sans []string) func([][]byte, [][]*x509.Certificate) error {
+	return func(_ [][]byte, chains [][]*x509.Certificate) error {
+		if len(chains) == 0 || len(chains[0]) == 0 {
+			return nil
+		}
+		id := identity.New(chains[0][0])
+		for _, p := range subjects {
+			if identity.Match(p, id.Subject) || identity.Match(p, id.CommonName) {
+				return nil
+			}
+		}
+		for _, p := range sans {
+			for _, san := range id.SANs() {
+				if identity.Match(p, san) {
+					return nil
+				}
+			}
+		}
+		return errors.Wrapf(ErrClientNotAllowed, "subject %q", id.Subject)
+	}
+}
+
 // NewX509CertPool returns *x509.CertPool struct or error.
 // The CertPool will read the certificate from the path, and append the content to the system certificate pool, and return.
 func NewX509CertPool(path string) (*x509.CertPool, error) {
-	var pool *x509.CertPool
 	c, err := ioutil.ReadFile(path)
-	if err == nil && c != nil {
-		pool, err = x509.SystemCertPool()
-		if err != nil || pool == nil {
-			pool = x509.NewCertPool()
-		}
-		if !pool.AppendCertsFromPEM(c) {
-			err = errors.New("Certification Failed")
-		}
+	if err != nil || c == nil {
+		return nil, err
+	}
+	return newX509CertPool(c)
+}
+
+// newX509CertPool returns *x509.CertPool which has the PEM encoded certificate in addition to the system certificate pool.
+func newX509CertPool(c []byte) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(c) {
+		return pool, errors.New("Certification Failed")
 	}
-	return pool, err
+	return pool, nil
 }
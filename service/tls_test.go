@@ -3,9 +3,11 @@ package service
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -136,7 +138,7 @@ func TestNewTLSConfig(t *testing.T) {
 						}
 
 						if !match {
-							return fmt.Errorf("CurvePreferences not Find :\twant %s", string(want.MinVersion))
+							return fmt.Errorf("CurvePreferences not Find :\twant %d", want.MinVersion)
 						}
 					}
 					return nil
@@ -314,6 +316,7 @@ func TestNewTLSConfig(t *testing.T) {
 
 				return nil
 			},
+			wantErr: true,
 		},
 		{
 			name: "cert file not found return value ClientAuth test.",
@@ -348,6 +351,7 @@ func TestNewTLSConfig(t *testing.T) {
 
 				return nil
 			},
+			wantErr: true,
 		},
 		{
 			name: "CA file not found return value ClientAuth test.",
@@ -394,12 +398,12 @@ func TestNewTLSConfig(t *testing.T) {
 			}
 
 			got, err := NewTLSConfig(tt.args.cfg)
-			if err != nil {
+			if (err != nil) != tt.wantErr {
 				t.Errorf("NewTLSConfig() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 
-			if tt.checkFunc != nil {
+			if err == nil && tt.checkFunc != nil {
 
 				err = tt.checkFunc(got, tt.want)
 				if err != nil {
@@ -489,3 +493,198 @@ func TestNewX509CertPool(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTLSConfig_ClientAuth(t *testing.T) {
+	os.Setenv("test_ClientAuth_CertKey", "./assets/dummyServer.crt")
+	os.Setenv("test_ClientAuth_KeyKey", "./assets/dummyServer.key")
+	os.Setenv("test_ClientAuth_CAKey", "./assets/dummyCa.pem")
+	defer func() {
+		os.Unsetenv("test_ClientAuth_CertKey")
+		os.Unsetenv("test_ClientAuth_KeyKey")
+		os.Unsetenv("test_ClientAuth_CAKey")
+	}()
+
+	tests := []struct {
+		name       string
+		caKey      string
+		clientAuth string
+		patterns   []string
+		want       tls.ClientAuthType
+		wantErr    error
+	}{
+		{
+			name:  "CA certificate requires and verifies the client certificate by default",
+			caKey: "test_ClientAuth_CAKey",
+			want:  tls.RequireAndVerifyClientCert,
+		},
+		{
+			name:       "None mode does not request the client certificate",
+			caKey:      "test_ClientAuth_CAKey",
+			clientAuth: config.ClientAuthNone,
+			want:       tls.NoClientCert,
+		},
+		{
+			name:       "Request mode works without the CA certificate",
+			clientAuth: config.ClientAuthRequest,
+			want:       tls.RequestClientCert,
+		},
+		{
+			name:       "Require any mode works without the CA certificate",
+			clientAuth: config.ClientAuthRequireAny,
+			want:       tls.RequireAnyClientCert,
+		},
+		{
+			name:       "Verify if given mode verifies the given client certificate",
+			caKey:      "test_ClientAuth_CAKey",
+			clientAuth: config.ClientAuthVerifyIfGiven,
+			patterns:   []string{"*.example.com"},
+			want:       tls.VerifyClientCertIfGiven,
+		},
+		{
+			name:       "Verify mode requires the CA certificate",
+			clientAuth: config.ClientAuthRequireAndVerify,
+			wantErr:    ErrClientCANotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewTLSConfig(config.TLS{
+				CertKey:           "test_ClientAuth_CertKey",
+				KeyKey:            "test_ClientAuth_KeyKey",
+				CAKey:             tt.caKey,
+				ClientAuth:        tt.clientAuth,
+				AllowedClientSANs: tt.patterns,
+			})
+			if err != tt.wantErr {
+				t.Fatalf("NewTLSConfig() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.ClientAuth != tt.want {
+				t.Errorf("NewTLSConfig() ClientAuth = %v, want %v", got.ClientAuth, tt.want)
+			}
+			if (got.VerifyPeerCertificate != nil) != (len(tt.patterns) > 0) {
+				t.Errorf("NewTLSConfig() VerifyPeerCertificate is set = %v", got.VerifyPeerCertificate != nil)
+			}
+		})
+	}
+}
+
+func Test_verifyClient(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:   "client-1",
+			Organization: []string{"example"},
+		},
+		DNSNames: []string{"client.example.com"},
+	}
+	tests := []struct {
+		name     string
+		subjects []string
+		sans     []string
+		chains   [][]*x509.Certificate
+		wantErr  bool
+	}{
+		{
+			name:     "Subject pattern matches the distinguished name",
+			subjects: []string{"CN=client-*,O=example"},
+			chains:   [][]*x509.Certificate{{cert}},
+		},
+		{
+			name:     "Subject pattern matches the common name",
+			subjects: []string{"client-*"},
+			chains:   [][]*x509.Certificate{{cert}},
+		},
+		{
+			name:   "SAN pattern matches the DNS name",
+			sans:   []string{"*.example.com"},
+			chains: [][]*x509.Certificate{{cert}},
+		},
+		{
+			name:     "Certificate matching none of the patterns is rejected",
+			subjects: []string{"admin"},
+			sans:     []string{"*.example.org"},
+			chains:   [][]*x509.Certificate{{cert}},
+			wantErr:  true,
+		},
+		{
+			name:     "Client without the certificate is not rejected",
+			subjects: []string{"admin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyClient(tt.subjects, tt.sans)(nil, tt.chains)
+			if (err != nil) != tt.wantErr || (err != nil && errors.Cause(err) != ErrClientNotAllowed) {
+				t.Errorf("verifyClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_applyProtocols(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.TLS
+		want    *tls.Config
+		wantErr bool
+	}{
+		{
+			name: "Defaults are kept when nothing is defined",
+			want: &tls.Config{
+				MinVersion:       tls.VersionTLS12,
+				CurvePreferences: []tls.CurveID{tls.CurveP256},
+			},
+		},
+		{
+			name: "Versions, cipher suites and curves are applied",
+			cfg: config.TLS{
+				MinVersion:       "1.1",
+				MaxVersion:       "1.2",
+				CipherSuites:     []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+				CurvePreferences: []string{"X25519", "P384"},
+			},
+			want: &tls.Config{
+				MinVersion:       tls.VersionTLS11,
+				MaxVersion:       tls.VersionTLS12,
+				CipherSuites:     []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+				CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP384},
+			},
+		},
+		{
+			name:    "Unsupported version is error",
+			cfg:     config.TLS{MaxVersion: "2.0"},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported cipher suite is error",
+			cfg:     config.TLS{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+			wantErr: true,
+		},
+		{
+			name:    "Unsupported curve is error",
+			cfg:     config.TLS{CurvePreferences: []string{"P224"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := &tls.Config{
+				MinVersion:       tls.VersionTLS12,
+				CurvePreferences: []tls.CurveID{tls.CurveP256},
+			}
+			err := applyProtocols(got, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyProtocols() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.MinVersion != tt.want.MinVersion || got.MaxVersion != tt.want.MaxVersion ||
+				!reflect.DeepEqual(got.CipherSuites, tt.want.CipherSuites) || !reflect.DeepEqual(got.CurvePreferences, tt.want.CurvePreferences) {
+				t.Errorf("applyProtocols() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
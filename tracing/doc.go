@@ -0,0 +1,3 @@
+// Package tracing starts the spans of the REST requests and RPCs by the OpenTelemetry SDK, propagates the W3C Trace Context (traceparent and tracestate),
+// and exports the finished spans to stdout, a file, or an OTLP/HTTP collector.
+package tracing
@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// defaultOTLPTimeout represents the timeout of a export request when config.OTLP.Timeout is empty
+	defaultOTLPTimeout = 10 * time.Second
+)
+
+// exporter logs the export errors of the otel exporter, and closes the span file on Shutdown.
+type exporter struct {
+	sdktrace.SpanExporter
+	log logger.Logger
+
+	// file is the span file of the "file" exporter, it is nil for the other exporters.
+	file io.Closer
+}
+
+// newExporter returns the otel exporter defined in cfg, which logs the export errors by l.
+func newExporter(cfg config.Tracing, l logger.Logger) (sdktrace.SpanExporter, error) {
+	e := &exporter{
+		log: l,
+	}
+
+	var err error
+	switch cfg.Exporter {
+	case "", "stdout":
+		e.SpanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		f, ferr := os.OpenFile(cfg.FilePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if ferr != nil {
+			return nil, errors.Wrap(ferr, "failed to open the span file")
+		}
+		e.file = f
+		e.SpanExporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	case "otlp":
+		e.SpanExporter, err = newOTLPExporter(cfg.OTLP)
+	default:
+		return nil, errors.Errorf("unsupported span exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		if e.file != nil {
+			e.file.Close()
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// newOTLPExporter returns the otel exporter which posts the spans to the OTLP/HTTP endpoint in the protobuf encoding.
+func newOTLPExporter(cfg config.OTLP) (sdktrace.SpanExporter, error) {
+	timeout := defaultOTLPTimeout
+	if cfg.Timeout != "" {
+		dur, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		timeout = dur
+	}
+
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid OTLP endpoint")
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithTimeout(timeout),
+	}
+	if u.Path != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	if u.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+
+	if len(cfg.Headers) > 0 {
+		headers := make(map[string]string, len(cfg.Headers))
+		for _, h := range cfg.Headers {
+			kv := strings.SplitN(h, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, errors.New("invalid OTLP header, must be \"Key=Value\"")
+			}
+			headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+
+	// the HTTP client does not connect until the first export
+	return otlptracehttp.New(context.Background(), opts...)
+}
+
+// ExportSpans logs the export error instead of returning it, since the batch span processor passes it to the global error handler of otel.
+func (e *exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	if err != nil {
+		e.log.Errorf("failed to export %d spans: %v", len(spans), err)
+	}
+	return nil
+}
+
+func (e *exporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if e.file == nil {
+		return err
+	}
+	cerr := e.file.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
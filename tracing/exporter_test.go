@@ -0,0 +1,195 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+)
+
+func Test_newExporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		cfg     config.Tracing
+		wantErr bool
+	}{
+		{
+			name: "Default exporter is stdout",
+			cfg:  config.Tracing{},
+		},
+		{
+			name: "File exporter",
+			cfg: config.Tracing{
+				Exporter: "file",
+				FilePath: filepath.Join(dir, "spans.json"),
+			},
+		},
+		{
+			name: "File exporter without directory returns error",
+			cfg: config.Tracing{
+				Exporter: "file",
+				FilePath: filepath.Join(dir, "missing", "spans.json"),
+			},
+			wantErr: true,
+		},
+		{
+			name: "OTLP exporter",
+			cfg: config.Tracing{
+				Exporter: "otlp",
+				OTLP: config.OTLP{
+					Endpoint: "http://localhost:4318/v1/traces",
+					Headers:  []string{"Authorization=Bearer token"},
+					Timeout:  "5s",
+				},
+			},
+		},
+		{
+			name: "OTLP exporter with invalid header returns error",
+			cfg: config.Tracing{
+				Exporter: "otlp",
+				OTLP: config.OTLP{
+					Endpoint: "http://localhost:4318/v1/traces",
+					Headers:  []string{"Authorization"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "OTLP exporter with invalid timeout returns error",
+			cfg: config.Tracing{
+				Exporter: "otlp",
+				OTLP: config.OTLP{
+					Endpoint: "http://localhost:4318/v1/traces",
+					Timeout:  "10",
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unsupported exporter returns error",
+			cfg: config.Tracing{
+				Exporter: "zipkin",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := newExporter(tt.cfg, logger.Nop())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				e.Shutdown(context.Background())
+			}
+		})
+	}
+}
+
+func Test_exporter_file(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tracing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spans.json")
+	tr, err := New(config.Tracing{
+		ServiceName: "test",
+		Exporter:    "file",
+		FilePath:    path,
+	}, logger.Nop())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, sp := tr.Start(context.Background(), "query")
+	sp.End()
+	if err = tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var got struct {
+		Name        string
+		SpanContext struct {
+			TraceID string
+		}
+	}
+	if len(lines) != 1 || json.Unmarshal([]byte(lines[0]), &got) != nil {
+		t.Fatalf("span file = %s, want a JSON line", b)
+	}
+	if got.Name != "query" || got.SpanContext.TraceID != sp.SpanContext().TraceID().String() {
+		t.Errorf("span = %+v, want %s of trace %s", got, "query", sp.SpanContext().TraceID())
+	}
+}
+
+func Test_exporter_otlp(t *testing.T) {
+	var path, auth, ctype string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		auth = r.Header.Get("Authorization")
+		ctype = r.Header.Get("Content-Type")
+	}))
+	defer srv.Close()
+
+	buf := new(bytes.Buffer)
+	cfg := config.Tracing{
+		Exporter: "otlp",
+		OTLP: config.OTLP{
+			Endpoint: srv.URL + "/v1/traces",
+			Headers:  []string{"Authorization=Bearer token"},
+		},
+	}
+	tr, err := New(cfg, logger.NewWriter(buf, logger.InfoLevel, false, false))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, sp := tr.Start(context.Background(), "query")
+	sp.End()
+	if err = tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if path != "/v1/traces" || auth != "Bearer token" || ctype != "application/x-protobuf" {
+		t.Errorf("export request path = %s, Authorization = %s, Content-Type = %s", path, auth, ctype)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("log = %q, want no export error", buf.String())
+	}
+
+	// the bad request is not retried, so that the export error is logged immediately
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	tr, err = New(cfg, logger.NewWriter(buf, logger.InfoLevel, false, false))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, sp = tr.Start(context.Background(), "query")
+	sp.End()
+	if err = tr.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "failed to export 1 spans") {
+		t.Errorf("log = %q, want the export error", buf.String())
+	}
+}
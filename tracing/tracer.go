@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/kpango/golang-server-template/config"
+	"github.com/kpango/golang-server-template/logger"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+const (
+	// defaultServiceName represents the service name when config.Tracing.ServiceName is empty
+	defaultServiceName = "server"
+
+	// instrumentationName represents the instrumentation scope name of the spans started by Tracer.Start
+	instrumentationName = "github.com/kpango/golang-server-template/tracing"
+)
+
+// Tracer represents the span factory and the instrumentation of the REST and gRPC servers.
+type Tracer interface {
+	// Start returns a copy of ctx which carries the new span, and the span.
+	// The span is the child of the span in ctx, or the root span of a new trace when ctx has no span.
+	Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span)
+
+	// InstrumentHandler returns the http.Handler which starts the server span of the route, continuing the trace of the traceparent header.
+	// The span is carried by the request context passed to h.
+	InstrumentHandler(route string, h http.Handler) http.Handler
+
+	// ServerHandler returns the stats.Handler of the gRPC server, which starts the server span of every RPC, continuing the trace of the traceparent metadata.
+	// The gRPC-Web request headers are also delivered as the metadata by the grpcweb wrapper.
+	ServerHandler() stats.Handler
+
+	// Shutdown exports the queued spans, and closes the exporter.
+	Shutdown(ctx context.Context) error
+}
+
+type tracer struct {
+	provider   *sdktrace.TracerProvider
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// New returns the Tracer which exports the spans by the exporter defined in cfg, and logs the export errors by l.
+// The root spans are sampled by cfg.SamplingRatio, and the child spans of the propagated traces follow the sampling decision of the caller.
+func New(cfg config.Tracing, l logger.Logger) (Tracer, error) {
+	e, err := newExporter(cfg, l)
+	if err != nil {
+		return nil, err
+	}
+
+	name := cfg.ServiceName
+	if name == "" {
+		name = defaultServiceName
+	}
+	ratio := cfg.SamplingRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	return newTracer(name, ratio, sdktrace.WithBatcher(e)), nil
+}
+
+// newTracer returns the tracer, which the spans are processed by sp, e.g. the syncer of the in-memory exporter for tests.
+func newTracer(name string, ratio float64, sp sdktrace.TracerProviderOption) *tracer {
+	provider := sdktrace.NewTracerProvider(
+		sp,
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(name))),
+	)
+	return &tracer{
+		provider:   provider,
+		tracer:     provider.Tracer(instrumentationName),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+func (t *tracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return t.tracer.Start(ctx, name, opts...)
+}
+
+func (t *tracer) InstrumentHandler(route string, h http.Handler) http.Handler {
+	return otelhttp.NewHandler(otelhttp.WithRouteTag(route, h), route,
+		otelhttp.WithTracerProvider(t.provider),
+		otelhttp.WithPropagators(t.propagator),
+	)
+}
+
+func (t *tracer) ServerHandler() stats.Handler {
+	return otelgrpc.NewServerHandler(
+		otelgrpc.WithTracerProvider(t.provider),
+		otelgrpc.WithPropagators(t.propagator),
+	)
+}
+
+func (t *tracer) Shutdown(ctx context.Context) error {
+	return t.provider.Shutdown(ctx)
+}
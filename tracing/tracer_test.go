@@ -0,0 +1,191 @@
+package tracing
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const (
+	testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	testParentID    = "00f067aa0ba902b7"
+)
+
+// attributeValue returns the value of the attribute key of the span, or the invalid value when the span does not have it.
+func attributeValue(s tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func Test_tracer_InstrumentHandler(t *testing.T) {
+	type test struct {
+		name       string
+		header     map[string]string
+		code       int
+		wantTrace  string
+		wantParent string
+		wantStatus codes.Code
+	}
+	tests := []test{
+		{
+			name: "Test root span of request without traceparent",
+			code: http.StatusOK,
+		},
+		{
+			name: "Test child span of request with traceparent",
+			header: map[string]string{
+				"traceparent": testTraceparent,
+			},
+			code:       http.StatusInternalServerError,
+			wantTrace:  testTraceID,
+			wantParent: testParentID,
+			wantStatus: codes.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tracetest.NewInMemoryExporter()
+			// the spans are exported when they end, and the in-memory exporter clears them on Shutdown
+			tr := newTracer("test", 1, sdktrace.WithSyncer(e))
+			defer tr.Shutdown(context.Background())
+
+			var inner trace.SpanContext
+			h := tr.InstrumentHandler("Sample Handler", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inner = trace.SpanContextFromContext(r.Context())
+				w.WriteHeader(tt.code)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/sample?q=1", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := e.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("exported spans = %d, want %d", len(spans), 1)
+			}
+			got := spans[0]
+			if got.SpanContext.SpanID() != inner.SpanID() || got.Name != "Sample Handler" || got.SpanKind != trace.SpanKindServer {
+				t.Errorf("span = %+v, want the span passed to the handler", got)
+			}
+			if tt.wantTrace != "" && (got.SpanContext.TraceID().String() != tt.wantTrace || got.Parent.SpanID().String() != tt.wantParent) {
+				t.Errorf("span trace = %s, parent = %s, want %s, %s", got.SpanContext.TraceID(), got.Parent.SpanID(), tt.wantTrace, tt.wantParent)
+			}
+			if attributeValue(got, "http.status_code").AsInt64() != int64(tt.code) || attributeValue(got, "http.route").AsString() != "Sample Handler" {
+				t.Errorf("span attributes = %v", got.Attributes)
+			}
+			if got.Status.Code != tt.wantStatus {
+				t.Errorf("span status = %s, want %s", got.Status.Code, tt.wantStatus)
+			}
+			if v, ok := got.Resource.Set().Value("service.name"); !ok || v.AsString() != "test" {
+				t.Errorf("span resource = %v, want service.name %s", got.Resource, "test")
+			}
+		})
+	}
+}
+
+func Test_tracer_ServerHandler(t *testing.T) {
+	e := tracetest.NewInMemoryExporter()
+	tr := newTracer("test", 1, sdktrace.WithSyncer(e))
+	defer tr.Shutdown(context.Background())
+
+	// the interceptor starts the child span, so that the span of the RPC is verified to be carried by the context of the interceptors
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.StatsHandler(tr.ServerHandler()),
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, child := tr.Start(ctx, "query")
+			defer child.End()
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", testTraceparent)
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: "unknown",
+	})
+	if status.Code(err) != grpccodes.NotFound {
+		t.Errorf("Check() error = %v, want %v", err, grpccodes.NotFound)
+	}
+
+	srv.GracefulStop()
+	spans := e.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported spans = %d, want %d", len(spans), 2)
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "grpc.health.v1.Health/Check" || server.SpanKind != trace.SpanKindServer || server.Parent.SpanID().String() != testParentID || server.Status.Code != codes.Error {
+		t.Errorf("server span = %+v", server)
+	}
+	if attributeValue(server, "rpc.service").AsString() != "grpc.health.v1.Health" || attributeValue(server, "rpc.grpc.status_code").AsInt64() != int64(grpccodes.NotFound) {
+		t.Errorf("server span attributes = %v", server.Attributes)
+	}
+	if child.SpanContext.TraceID() != server.SpanContext.TraceID() || child.Parent.SpanID() != server.SpanContext.SpanID() || child.SpanKind != trace.SpanKindInternal {
+		t.Errorf("child span = %+v, want the child of %s", child, server.SpanContext.SpanID())
+	}
+}
+
+func Test_tracer_sampling(t *testing.T) {
+	e := tracetest.NewInMemoryExporter()
+	tr := newTracer("test", 0.000001, sdktrace.WithSyncer(e))
+	defer tr.Shutdown(context.Background())
+
+	remote := func(flags trace.TraceFlags) context.Context {
+		tid, _ := trace.TraceIDFromHex(testTraceID)
+		sid, _ := trace.SpanIDFromHex(testParentID)
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    tid,
+			SpanID:     sid,
+			TraceFlags: flags,
+			Remote:     true,
+		}))
+	}
+
+	// the root span is very unlikely to be sampled, but the sampled parent is respected
+	_, root := tr.Start(context.Background(), "root")
+	root.End()
+	_, child := tr.Start(remote(trace.FlagsSampled), "child")
+	child.End()
+	_, dropped := tr.Start(remote(0), "dropped")
+	dropped.End()
+
+	spans := e.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "child" {
+		t.Errorf("exported spans = %+v, want only %s", spans, "child")
+	}
+}
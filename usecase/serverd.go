@@ -2,34 +2,191 @@ package usecase
 
 import (
 	"context"
+	"net/http"
+	"sync"
+	"time"
 
+	"github.com/kpango/golang-server-template/accesslog"
 	"github.com/kpango/golang-server-template/config"
 	"github.com/kpango/golang-server-template/handler/grpc"
 	"github.com/kpango/golang-server-template/handler/rest"
+	"github.com/kpango/golang-server-template/identity"
+	"github.com/kpango/golang-server-template/logger"
+	"github.com/kpango/golang-server-template/metrics"
+	"github.com/kpango/golang-server-template/recovery"
+	"github.com/kpango/golang-server-template/requestid"
 	"github.com/kpango/golang-server-template/router"
 	"github.com/kpango/golang-server-template/service"
+	"github.com/kpango/golang-server-template/tracing"
+	grpcgo "google.golang.org/grpc"
+)
+
+const (
+	// tracerShutdownTimeout represents the timeout to export the queued spans on shutdown
+	tracerShutdownTimeout = 5 * time.Second
+
+	// defaultLevelPath represents the path of the admin endpoint of the log level when config.Logging.LevelPath is empty
+	defaultLevelPath = "/loglevel"
+)
+
+var (
+	// defaultTransport guards http.DefaultTransport, which is tuned and wrapped only by the first New,
+	// since New is called again, e.g. by the tests, and each call must not add another wrapper to the process-wide transport
+	defaultTransport sync.Once
 )
 
 type Runner interface {
 	Start(ctx context.Context) chan []error
+	Reload(cfg config.Config) error
 }
 
 type run struct {
+	mu     sync.Mutex
 	cfg    config.Config
+	router router.Router
 	server service.Server
+	log    logger.Logger
+
+	// tracer is nil when the tracing is disabled
+	tracer tracing.Tracer
 }
 
-func New(cfg config.Config) (Runner, error) {
+// New returns the Runner of the servers defined in cfg, which logs by l.
+// The level of l can be changed through the admin endpoint served by the health check server at config.Logging.LevelPath.
+func New(cfg config.Config, l logger.Logger) (Runner, error) {
+	m, err := metrics.New()
+	if err != nil {
+		return nil, err
+	}
+
+	// the request ID and the client identity interceptors are the outermost, so that the other interceptors can read them,
+	// the access log interceptors are the next, so that the latency covers the other interceptors,
+	// the metrics interceptors are the next, so that the recovered panic is counted with its status code,
+	// and the recovery interceptors are the innermost, so that the recovered panic is recorded as codes.Internal.
+	// The spans of the RPCs are started by the stats handler of the tracer, so that they cover all the interceptors
+	rec := recovery.New(cfg.Server.Mode == config.ModeDevelopment, m, l)
+	uis := []grpcgo.UnaryServerInterceptor{m.UnaryServerInterceptor(), rec.UnaryServerInterceptor()}
+	sis := []grpcgo.StreamServerInterceptor{m.StreamServerInterceptor(), rec.StreamServerInterceptor()}
+
+	var al accesslog.Logger
+	if cfg.Logging.Access.Enabled {
+		al = accesslog.New(cfg.Logging.Access, l)
+		uis = append([]grpcgo.UnaryServerInterceptor{al.UnaryServerInterceptor()}, uis...)
+		sis = append([]grpcgo.StreamServerInterceptor{al.StreamServerInterceptor()}, sis...)
+	}
+	uis = append([]grpcgo.UnaryServerInterceptor{
+		requestid.UnaryServerInterceptor(cfg.Server.RequestIDHeader),
+		identity.UnaryServerInterceptor(),
+	}, uis...)
+	sis = append([]grpcgo.StreamServerInterceptor{
+		requestid.StreamServerInterceptor(cfg.Server.RequestIDHeader),
+		identity.StreamServerInterceptor(),
+	}, sis...)
+
+	opts := []grpcgo.ServerOption{
+		grpcgo.UnaryInterceptor(grpc.ChainUnaryServer(uis...)),
+		grpcgo.StreamInterceptor(grpc.ChainStreamServer(sis...)),
+	}
+	var tr tracing.Tracer
+	if cfg.Tracing.Enabled {
+		tr, err = tracing.New(cfg.Tracing, l)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpcgo.StatsHandler(tr.ServerHandler()))
+	}
+
+	rt := router.New(cfg.Server, rest.New(), m, tr, al, l)
+
+	setupDefaultTransport(cfg.Server.RequestIDHeader)
+
+	srv := service.NewServer(cfg.Server,
+		rt,
+		grpc.New(opts...).GetGRPCServer(),
+		m,
+		al,
+		l,
+	)
+
+	lpath := cfg.Logging.LevelPath
+	if lpath == "" {
+		lpath = defaultLevelPath
+	}
+	srv.HandleAdmin(lpath, logger.Handler(l))
+
 	return &run{
-		cfg: cfg,
-		server: service.NewServer(cfg.Server,
-			router.New(cfg.Server,
-				rest.New()),
-			grpc.New().GetGRPCServer(),
-		),
+		cfg:    cfg,
+		router: rt,
+		server: srv,
+		log:    l,
+		tracer: tr,
 	}, nil
 }
 
+// Start starts the servers, and returns the channel which receives the errors after the servers stop.
+// The queued spans are exported before the errors are sent.
 func (t *run) Start(ctx context.Context) chan []error {
-	return t.server.ListenAndServe(ctx)
+	ech := t.server.ListenAndServe(ctx)
+	if t.tracer == nil {
+		return ech
+	}
+
+	tech := make(chan []error, 1)
+	go func() {
+		errs := <-ech
+		sctx, cancel := context.WithTimeout(context.Background(), tracerShutdownTimeout)
+		defer cancel()
+		if err := t.tracer.Shutdown(sctx); err != nil {
+			errs = append(errs, err)
+		}
+		tech <- errs
+	}()
+	return tech
+}
+
+// Reload applies the live reloadable part of cfg, including the log level, to the running router and server.
+// When cfg cannot be applied, it returns error and the previous configuration keeps running.
+func (t *run) Reload(cfg config.Config) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	lv, err := logger.ParseLevel(cfg.Logging.Level)
+	if err != nil {
+		return err
+	}
+
+	err = t.router.Reload(cfg.Server)
+	if err != nil {
+		return err
+	}
+
+	err = t.server.Reload(cfg.Server)
+	if err != nil {
+		// restore the handler timeout which is already applied
+		t.router.Reload(t.cfg.Server)
+		return err
+	}
+
+	// the level changed through the admin endpoint is kept, unless the configured level is changed
+	if cfg.Logging.Level != t.cfg.Logging.Level {
+		t.log.SetLevel(lv)
+	}
+
+	for _, field := range config.RestartRequired(t.cfg, cfg) {
+		t.log.Warnf("configuration %s is changed, it requires restart to be applied", field)
+	}
+
+	t.cfg = cfg
+	return nil
+}
+
+// setupDefaultTransport tunes the connection pool of http.DefaultTransport, and wraps it so that the outbound requests via it carry the request ID of the request context in the header.
+// Only the first call takes effect, the header is not changed until the process restarts, as server.request_id_header requires the restart.
+func setupDefaultTransport(header string) {
+	defaultTransport.Do(func() {
+		if t, ok := http.DefaultTransport.(*http.Transport); ok {
+			t.MaxIdleConnsPerHost = 32
+		}
+		http.DefaultTransport = requestid.NewTransport(http.DefaultTransport, header)
+	})
 }
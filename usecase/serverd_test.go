@@ -0,0 +1,27 @@
+package usecase
+
+import (
+	"net/http"
+	"testing"
+)
+
+func Test_setupDefaultTransport(t *testing.T) {
+	orig := http.DefaultTransport
+	defer func() {
+		http.DefaultTransport = orig
+	}()
+
+	setupDefaultTransport("X-Request-ID")
+	wrapped := http.DefaultTransport
+	setupDefaultTransport("X-Request-ID")
+
+	if http.DefaultTransport != wrapped {
+		t.Error("setupDefaultTransport() wraps http.DefaultTransport again")
+	}
+	if wrapped == orig {
+		t.Error("setupDefaultTransport() does not wrap http.DefaultTransport")
+	}
+	if tr, ok := orig.(*http.Transport); ok && tr.MaxIdleConnsPerHost != 32 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want %d", tr.MaxIdleConnsPerHost, 32)
+	}
+}